@@ -0,0 +1,154 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juju/loggo"
+	"github.com/mattnappo/yearbook/database"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// analyticsBuffer is the number of usage events that can wait to be
+	// written before new ones are dropped.
+	analyticsBuffer = 4096
+
+	// analyticsBatchSize is the most usage events written at once.
+	analyticsBatchSize = 256
+
+	// analyticsFlushInterval is the longest a usage event waits before it
+	// is written.
+	analyticsFlushInterval = 5 * time.Second
+
+	// aggregateInterval is how often usage events are added to the daily
+	// usage totals.
+	aggregateInterval = time.Hour
+)
+
+// analytics writes usage events to the database in batches, so that
+// recording them does not slow down requests.
+type analytics struct {
+	database *database.Database
+	log      *loggo.Logger
+
+	events chan *models.UsageEvent
+	wg     sync.WaitGroup
+
+	mux    sync.RWMutex // Guards closing the events channel
+	closed bool
+}
+
+// newAnalytics constructs a new *analytics.
+func newAnalytics(db *database.Database, log *loggo.Logger) *analytics {
+	return &analytics{
+		database: db,
+		log:      log,
+		events:   make(chan *models.UsageEvent, analyticsBuffer),
+	}
+}
+
+// start starts the batch writer.
+func (a *analytics) start() {
+	a.wg.Add(1)
+	go a.write()
+	a.log.Infof("started usage analytics")
+}
+
+// stop stops accepting events and waits for the buffered events to be
+// written.
+func (a *analytics) stop() {
+	a.mux.Lock()
+	a.closed = true
+	close(a.events)
+	a.mux.Unlock()
+
+	a.wg.Wait()
+	a.log.Infof("stopped usage analytics")
+}
+
+// record buffers a usage event to be written. The event is dropped if the
+// buffer is full or analytics were stopped.
+func (a *analytics) record(event *models.UsageEvent) {
+	a.mux.RLock()
+	defer a.mux.RUnlock()
+	if a.closed {
+		return
+	}
+
+	select {
+	case a.events <- event:
+	default:
+		a.log.Warningf("analytics buffer full, dropping usage event")
+	}
+}
+
+// write writes the buffered events whenever a batch fills up or the flush
+// interval passes, until the events channel is closed.
+func (a *analytics) write() {
+	defer a.wg.Done()
+
+	ticker := time.NewTicker(analyticsFlushInterval)
+	defer ticker.Stop()
+
+	batch := make([]*models.UsageEvent, 0, analyticsBatchSize)
+	flush := func() {
+		err := a.database.AddUsageEvents(batch)
+		if err != nil {
+			a.log.Errorf("could not write %d usage events: %s", len(batch), err)
+		}
+		batch = make([]*models.UsageEvent, 0, analyticsBatchSize)
+	}
+
+	for {
+		select {
+		case event, open := <-a.events:
+			if !open {
+				flush()
+				return
+			}
+			batch = append(batch, event)
+			if len(batch) >= analyticsBatchSize {
+				flush()
+			}
+		case <-ticker.C:
+			flush()
+		}
+	}
+}
+
+// recordUsage is the middleware that records a usage event for every
+// authenticated request.
+func (api *API) recordUsage() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		ctx.Next()
+
+		if api.analytics == nil || viewer(ctx) == "" {
+			return
+		}
+		api.analytics.record(&models.UsageEvent{
+			Username:  models.Username(viewer(ctx)),
+			Method:    ctx.Request.Method,
+			Route:     ctx.FullPath(),
+			Timestamp: time.Now(),
+		})
+	}
+}
+
+// aggregateUsage is the job that adds the usage events to the daily usage
+// totals and prunes the old events.
+func (api *API) aggregateUsage() error {
+	return api.database.AggregateUsage(time.Now(), api.config.AnalyticsRetention)
+}
+
+// getUsageReport gets the active users and the most popular routes.
+func (api *API) getUsageReport(ctx *gin.Context) {
+	report, err := api.database.GetUsageReport(time.Now())
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(report))
+}
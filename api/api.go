@@ -2,15 +2,15 @@
 package api
 
 import (
+	"context"
 	"fmt"
-	"io/ioutil"
 	"net/http"
-	"net/smtp"
 	"os"
 	"os/signal"
+	"path"
 	"path/filepath"
 	"strconv"
-	"strings"
+	"syscall"
 	"time"
 
 	"github.com/gin-contrib/sessions"
@@ -20,6 +20,8 @@ import (
 	"github.com/juju/loggo/loggocolor"
 	"github.com/mattnappo/yearbook/common"
 	"github.com/mattnappo/yearbook/database"
+	"github.com/mattnappo/yearbook/filter"
+	"github.com/mattnappo/yearbook/mail"
 	"github.com/mattnappo/yearbook/models"
 	"golang.org/x/oauth2"
 )
@@ -36,36 +38,40 @@ const (
 	defaultSessionTimeout = time.Minute * 30
 )
 
-var (
-	protocol = common.GetEnv("PROTOCOL")
-	// The frontend callback data
-	callbackProvider = common.GetEnv("PROVIDER")
-	callbackURL      = "%s://%s/oauth"
-)
+// callbackURL is the frontend OAuth2 callback.
+const callbackURL = "%s://%s/oauth"
 
 // API contains the API layer.
 type API struct {
+	server   *http.Server
 	router   *gin.Engine
+	metrics  *metrics
 	database *database.Database
 	log      *loggo.Logger
+	filter   filter.ContentFilter
+	outbox   *outbox
+	jobs     *jobs
+	hub      *hub
+	stats    *statsCache
+
+	analytics *analytics
+
+	metricsServer *http.Server
+
+	config *common.Config
 
 	root      string
 	oauthRoot string
 
-	port int64
-
 	oauthConfig *oauth2.Config
 	callbackURL string
 	cookieStore cookie.Store
 }
 
 // newAPI constructs a new API struct.
-func newAPI(port int64) (*API, error) {
+func newAPI(config *common.Config) (*API, error) {
 	// Generate the store
-	// Should use common.GenRandomToken
-	cookieStore := cookie.NewStore(
-		[]byte(common.GetEnv("COOKIE_SECRET")),
-	)
+	cookieStore := cookie.NewStore([]byte(config.CookieSecret))
 
 	cookieStore.Options(sessions.Options{
 		Path:   "/",
@@ -83,14 +89,18 @@ func newAPI(port int64) (*API, error) {
 	api := &API{
 		router:   r,
 		database: nil,
+		filter:   filter.NewWordFilter(filter.DefaultDenyList, nil),
+		hub:      newHub(),
+		stats:    &statsCache{},
+		metrics:  newMetrics(),
+
+		config: config,
 
 		root:      defaultAPIRoot,
 		oauthRoot: defaultOAuthRoot,
 
-		port: port,
-
 		callbackURL: fmt.Sprintf(
-			callbackURL, protocol, callbackProvider,
+			callbackURL, config.Protocol, config.Provider,
 		),
 		cookieStore: cookieStore,
 	}
@@ -100,8 +110,10 @@ func newAPI(port int64) (*API, error) {
 	if err != nil {
 		return nil, err
 	}
+	api.router.Use(api.measure())
 	api.initializeRoutes()
 	api.initializeOAuth()
+	api.initializeMetrics()
 
 	api.log.Infof("API server initialization complete")
 	return api, nil
@@ -133,11 +145,20 @@ func (api *API) check(err error, ctx *gin.Context, status ...int) bool {
 
 // initializeRoutes initializes the necessary routes.
 func (api *API) initializeRoutes() {
+	// Unsubscribe links are signed, so they do not need authorization
+	api.router.GET(path.Join(api.root, "unsubscribe"), api.confirmUnsubscribe)
+	api.router.POST(path.Join(api.root, "unsubscribe"), api.unsubscribe)
+
+	// Event streams are opened by browsers that cannot set headers, so
+	// they are authorized by the token cookie too
+	api.router.GET(path.Join(api.root, "stream"),
+		api.authorizeStream(), api.recordUsage(), api.stream)
+
 	// Create a group of protected routes (the main api routes)
 	protectedRoutes := api.router.Group(api.root)
 
 	// Require only authorized requests
-	protectedRoutes.Use(api.authorizeRequest())
+	protectedRoutes.Use(api.authorizeRequest(), api.recordUsage())
 	{
 		protectedRoutes.POST("createPost", api.createPost)
 		protectedRoutes.GET("getPost/:id", api.getPost)
@@ -146,6 +167,9 @@ func (api *API) initializeRoutes() {
 		protectedRoutes.GET("getnPosts/:n", api.getnPosts)
 		protectedRoutes.GET("getnPostsOffset/:n/:offset", api.getnPostsOffset)
 		protectedRoutes.DELETE("deletePost/:id", api.deletePost)
+		protectedRoutes.GET("getDrafts", api.getDrafts)
+		protectedRoutes.PATCH("updateDraft/:id", api.updateDraft)
+		protectedRoutes.DELETE("deleteDraft/:id", api.deleteDraft)
 
 		protectedRoutes.PATCH("updateUser", api.updateUser)
 		protectedRoutes.GET("getUser/:username", api.getUser)
@@ -157,49 +181,207 @@ func (api *API) initializeRoutes() {
 		protectedRoutes.GET("getUsers", api.getUsers)
 		protectedRoutes.GET("getSeniors", api.getSeniors)
 		protectedRoutes.GET("getUsernames", api.getUsernames)
+		protectedRoutes.GET("users/suggest", api.suggestUsers)
+		protectedRoutes.GET("suggestions/reciprocate", api.getReciprocations)
+		protectedRoutes.GET("suggestions/someoneNew", api.writeToSomeoneNew)
+		protectedRoutes.PATCH("setOpenToSuggestions", api.setOpenToSuggestions)
+		protectedRoutes.GET("getStats", api.getStats)
+		protectedRoutes.POST("reportPost/:id", api.reportPost)
+		protectedRoutes.POST("hidePost/:id", api.hidePost)
+		protectedRoutes.POST("removeSelfFromPost/:id", api.removeSelfFromPost)
+		protectedRoutes.POST("block/:username", api.blockUser)
+		protectedRoutes.DELETE("block/:username", api.unblockUser)
+		protectedRoutes.GET("getBlocked", api.getBlocked)
+		protectedRoutes.PATCH("setDoNotAcceptPosts", api.setDoNotAcceptPosts)
+		protectedRoutes.GET("getNotificationPreferences", api.getNotificationPreferences)
+		protectedRoutes.PATCH("updateNotificationPreferences", api.updateNotificationPreferences)
+		protectedRoutes.GET("getNotifications/:n/:offset", api.getNotifications)
+		protectedRoutes.GET("getUnreadCount", api.getUnreadCount)
+		protectedRoutes.POST("markNotificationRead/:id", api.markNotificationRead)
+		protectedRoutes.POST("markAllNotificationsRead", api.markAllNotificationsRead)
+		protectedRoutes.POST("posts/:id/reactions/:emoji", api.addReaction)
+		protectedRoutes.DELETE("posts/:id/reactions/:emoji", api.removeReaction)
+		protectedRoutes.GET("posts/:id/comments/:n/:offset", api.getComments)
+		protectedRoutes.POST("posts/:id/comments", api.addComment)
+		protectedRoutes.PATCH("comments/:id", api.editComment)
+		protectedRoutes.DELETE("comments/:id", api.deleteComment)
+
+		moderator := api.requireRole(models.Moderator)
+		protectedRoutes.GET("getModerationQueue", moderator, api.getModerationQueue)
+		protectedRoutes.POST("approvePost/:id", moderator, api.approvePost)
+		protectedRoutes.POST("rejectPost/:id", moderator, api.rejectPost)
+		protectedRoutes.GET("getReports", moderator, api.getReports)
+		protectedRoutes.GET("getHiddenPosts/:username", moderator, api.getHiddenPosts)
+		protectedRoutes.POST("resolveReport/:id", moderator, api.resolveReport)
+		protectedRoutes.POST("dismissReport/:id", moderator, api.dismissReport)
+
+		admin := api.requireRole(models.Admin)
+		protectedRoutes.GET("getFilterWords", admin, api.getFilterWords)
+		protectedRoutes.POST("addFilterWord", admin, api.addFilterWord)
+		protectedRoutes.DELETE("deleteFilterWord/:word", admin, api.deleteFilterWord)
+		protectedRoutes.GET("getFailedEmails", admin, api.getFailedEmails)
+		protectedRoutes.POST("retryEmail/:id", admin, api.retryEmail)
+		protectedRoutes.GET("getEmailTemplates", admin, api.getEmailTemplates)
+		protectedRoutes.GET("previewEmail/:template", admin, api.previewEmail)
+		protectedRoutes.GET("getCoverage", admin, api.getCoverage)
+		protectedRoutes.GET("getUsageReport", admin, api.getUsageReport)
+		protectedRoutes.POST("hideFromLeaderboard/:username", admin, api.hideFromLeaderboard)
+		protectedRoutes.DELETE("hideFromLeaderboard/:username", admin, api.showOnLeaderboard)
 	}
 
 	api.log.Infof("initialized API server routes")
 }
 
-// StartAPIServer starts the API server.
-func StartAPIServer(port int64) error {
-	api, err := newAPI(port)
+// StartAPIServer starts the API server. It fails if the config is missing
+// any keys.
+func StartAPIServer(config *common.Config) error {
+	err := config.Validate()
+	if err != nil {
+		return err
+	}
+
+	api, err := newAPI(config)
 	if err != nil {
 		return err
 	}
 
-	api.database = database.Connect(false)
+	api.database, err = database.Connect(config.Database)
+	if err != nil {
+		return err
+	}
 	defer api.database.Disconnect()
 
-	api.log.Infof("API server to listen on port %d", port)
+	// Load the content filter lists from the database
+	err = api.reloadFilter()
+	if err != nil {
+		return err
+	}
 
-	// Catch intrerupt
+	// Start delivering the notifications in the outbox
+	api.outbox = newOutbox(
+		api.database, newMailer(config.Mail), api.metrics, api.log,
+	)
+	err = api.outbox.start()
+	if err != nil {
+		return err
+	}
+
+	// Start recording usage analytics
+	api.analytics = newAnalytics(api.database, api.log)
+	api.analytics.start()
+
+	// Start the background jobs
+	api.jobs = newJobs(api.log)
+	api.jobs.every("scheduled posts", scheduleCheckInterval, api.publishDuePosts)
+	api.jobs.every("usage aggregation", aggregateInterval, api.aggregateUsage)
+	if config.Mail.Enabled {
+		api.jobs.every("digest", digestCheckInterval, api.sendDigests)
+		api.jobs.every("reminder", reminderCheckInterval, api.sendReminders)
+	}
+
+	api.server = &http.Server{
+		Addr:         ":" + strconv.FormatInt(config.Port, 10),
+		Handler:      api.router,
+		ReadTimeout:  config.ReadTimeout,
+		WriteTimeout: config.WriteTimeout,
+		IdleTimeout:  config.IdleTimeout,
+	}
+
+	// Shut down when interrupted or terminated
 	c := make(chan os.Signal, 1)
-	signal.Notify(c, os.Interrupt)
-	go func(api *API) {
-		for sig := range c {
-			// Shutdown the API when ctrl-C is pressed
-			api.shutdown(sig)
-			os.Exit(0)
+	signal.Notify(c, os.Interrupt, syscall.SIGTERM)
+	defer signal.Stop(c)
+	done := make(chan error, 1)
+	go func() {
+		sig, caught := <-c
+		if caught {
+			done <- api.shutdown(sig, c)
+		}
+	}()
+
+	// Serve the metrics on their own address, if they have one
+	if api.metricsServer != nil {
+		go func() {
+			err := api.metricsServer.ListenAndServe()
+			if err != http.ErrServerClosed {
+				api.log.Errorf("could not serve metrics: %s", err)
+			}
+		}()
+	}
+
+	api.log.Infof("API server to listen on port %d", config.Port)
+	err = api.server.ListenAndServe()
+	if err != http.ErrServerClosed {
+		signal.Stop(c)
+		close(c)
+		if api.metricsServer != nil {
+			api.metricsServer.Close()
 		}
-	}(api)
+		api.stopWorkers()
+		return err
+	}
 
-	return api.router.Run(":" + strconv.FormatInt(port, 10))
+	// Wait for the requests and the workers to finish before the database
+	// is disconnected
+	return <-done
 }
 
-// shutdown shuts down the API.
-func (api *API) shutdown(sig os.Signal) {
+// shutdown shuts down the API, giving in-flight requests until the
+// shutdown timeout, or until another signal is caught on force, to finish.
+// The requests that are still running then are cut off, and the error says
+// so.
+func (api *API) shutdown(sig os.Signal, force <-chan os.Signal) error {
 	api.log.Debugf("caught %v", sig)
 	api.log.Infof("shutting down API server")
 
-	api.database.Disconnect()
-	api.log.Debugf("disconnected from database")
+	// Event streams never finish on their own, so they are closed first
+	api.hub.close()
+	api.log.Debugf("closed event streams")
 
-	api.router = nil
-	api.log.Debugf("destroyed router")
+	ctx, cancel := context.WithTimeout(
+		context.Background(), api.config.ShutdownTimeout,
+	)
+	defer cancel()
+
+	// Stop waiting for the requests if signalled again
+	go func() {
+		select {
+		case sig, caught := <-force:
+			if caught {
+				api.log.Infof("caught %v again, cutting off requests", sig)
+				cancel()
+			}
+		case <-ctx.Done():
+		}
+	}()
 
+	err := api.server.Shutdown(ctx)
+	if err != nil {
+		api.log.Warningf("requests did not finish: %s", err)
+		api.server.Close()
+	} else {
+		api.log.Debugf("drained requests")
+	}
+	if api.metricsServer != nil {
+		api.metricsServer.Close()
+	}
+
+	api.stopWorkers()
 	api.log.Infof("API server shut down")
+	return err
+}
+
+// stopWorkers stops the background workers, waiting for them to finish
+// what they are doing.
+func (api *API) stopWorkers() {
+	api.jobs.stop()
+	api.log.Debugf("stopped background jobs")
+
+	api.analytics.stop()
+	api.log.Debugf("stopped usage analytics")
+
+	api.outbox.stop()
+	api.log.Debugf("stopped outbox")
 }
 
 // initLogger initializes the api's logger.
@@ -242,81 +424,16 @@ func (api *API) initLogger() error {
 	return nil
 }
 
-// genEmailBody generates the body of a notification email given
-// a sender's username.
-func genEmailBody(sender string) string {
-	emailTemplate, _ := ioutil.ReadFile("email.txt")
-	return strings.ReplaceAll(
-		string(emailTemplate), "$$$SENDER$$$", sender,
-	)
-}
-
-// sendNotification sends an email to a user that they have
-// been congratulated.
-func (api *API) sendNotification(
-	sender models.Username,
-	recipients []models.Username,
-) error {
-	// Setup the authentication
-	auth := smtp.PlainAuth("",
-		common.NotifEmail,
-		common.NotifPassword,
-		common.NotifProvider,
-	)
-
-	htmlBody := genEmailBody(sender.Name())
-
-	// Setup the message
-	mime := "MIME-version: 1.0;\nContent-Type: text/html; charset=\"UTF-8\";\n\n"
-
-	var to []string
-	for _, recip := range recipients {
-		to = append(to, recip.Email())
+// newMailer constructs the mailer used to deliver notifications. Emails
+// are written to files instead of sent if a mail sink is set.
+func newMailer(config common.MailConfig) mail.Mailer {
+	if config.Sink != "" {
+		return &mail.FileMailer{Dir: config.Sink, From: config.From}
+	}
+	return &mail.SMTPMailer{
+		Host:     config.SMTPHost,
+		Port:     config.SMTPPort,
+		From:     config.From,
+		Password: config.Password,
 	}
-	msg := fmt.Sprintf("To: %s\r\nSubject: %s Congratulated you!\r\n"+
-		mime+"\r\n"+
-		"%s\r\n",
-		strings.Join(to, ","),
-		sender.Name(),
-		htmlBody,
-	)
-
-	// Actually send the email
-	err := smtp.SendMail(
-		fmt.Sprintf("%s:%d", common.NotifProvider, common.NotifPort),
-		auth, common.NotifEmail, to, []byte(msg),
-	)
-	return err
-}
-
-func (api *API) sendModEmail(post models.Post) error {
-	// Setup the authentication
-	auth := smtp.PlainAuth("",
-		common.NotifEmail,
-		common.NotifPassword,
-		common.NotifProvider,
-	)
-
-	body := fmt.Sprintf(
-		"Sender: %s\nRecipients: %v\nMessage: %s\nID: %d\nPostID: %s\n",
-		post.Sender, post.Recipients, post.Message, post.ID, post.PostID,
-	)
-
-	// Setup the message
-	to := []string{"mattnappo@gmail.com"}
-	msg := fmt.Sprintf("To: %s\r\nSubject: %s posted\r\n"+
-		"\r\n"+
-		"%s\r\n",
-		to,
-		post.Sender.Name(),
-		body,
-	)
-
-	// Actually send the email
-	err := smtp.SendMail(
-		fmt.Sprintf("%s:%d", common.NotifProvider, common.NotifPort),
-		auth, common.NotifEmail, to, []byte(msg),
-	)
-	return err
-
 }
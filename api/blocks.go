@@ -0,0 +1,137 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+var (
+	// errNoRecipients is thrown when none of the recipients of a post can
+	// receive it. It deliberately does not say why.
+	errNoRecipients = errors.New("could not post to the given recipients")
+
+	// errSeniorsMustAccept is thrown when a senior tries to stop accepting
+	// posts.
+	errSeniorsMustAccept = errors.New("seniors cannot turn off posts about them")
+
+	// errBlockSelf is thrown when a user tries to block themselves.
+	errBlockSelf = errors.New("cannot block yourself")
+
+	// errUserNotFound is thrown when a user that does not exist is blocked.
+	errUserNotFound = errors.New("user not found")
+)
+
+// blockUser handles a request to block a user.
+func (api *API) blockUser(ctx *gin.Context) {
+	blocker := models.Username(viewer(ctx))
+	blocked := models.Username(ctx.Param("username"))
+
+	if blocked == blocker {
+		api.check(errBlockSelf, ctx, http.StatusBadRequest)
+		return
+	}
+	_, err := api.database.GetUser(string(blocked))
+	if err != nil {
+		api.check(errUserNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	err = api.database.BlockUser(blocker, blocked)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s blocked a user", blocker)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// unblockUser handles a request to unblock a user.
+func (api *API) unblockUser(ctx *gin.Context) {
+	blocker := models.Username(viewer(ctx))
+	blocked := models.Username(ctx.Param("username"))
+
+	err := api.database.UnblockUser(blocker, blocked)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s unblocked a user", blocker)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// getBlocked gets the users that the requesting user has blocked.
+func (api *API) getBlocked(ctx *gin.Context) {
+	blocked, err := api.database.GetBlocked(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(blocked))
+}
+
+// setDoNotAcceptPosts handles a request to turn the requesting user's
+// "do not accept posts" setting on or off.
+func (api *API) setDoNotAcceptPosts(ctx *gin.Context) {
+	var request doNotAcceptPostsRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	username := viewer(ctx)
+	grade, err := api.database.GetUserGrade(username)
+	if api.check(err, ctx) {
+		return
+	}
+	if request.Enabled && grade == models.Senior {
+		api.check(errSeniorsMustAccept, ctx, http.StatusForbidden)
+		return
+	}
+
+	err = api.database.SetDoNotAcceptPosts(username, request.Enabled)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s set do not accept posts to %t", username, request.Enabled)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// allowedRecipients checks the recipients of a new post. Recipients who
+// have blocked the sender are silently dropped, and recipients who do not
+// accept posts are an error.
+func (api *API) allowedRecipients(
+	sender string,
+	recipients []string,
+) ([]string, error) {
+	users, err := api.database.GetUsers(recipients)
+	if err != nil {
+		return nil, err
+	}
+
+	// Recipients that are not in the database yet have no settings
+	blocked := make(map[string]bool)
+	for _, user := range users {
+		if user.DoNotAcceptPosts {
+			return nil, fmt.Errorf("%s is not accepting posts", user.Username)
+		}
+		if user.HasBlocked(models.Username(sender)) {
+			blocked[string(user.Username)] = true
+		}
+	}
+
+	var allowed []string
+	for _, recipient := range recipients {
+		if !blocked[recipient] {
+			allowed = append(allowed, recipient)
+		}
+	}
+	if len(allowed) == 0 {
+		return nil, errNoRecipients
+	}
+	return allowed, nil
+}
@@ -0,0 +1,236 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// maxCommentPage is the most top-level comments returned in one page.
+const maxCommentPage = 100
+
+var (
+	// errCommentNotFound is thrown when a comment does not exist.
+	errCommentNotFound = errors.New("comment not found")
+
+	// errInvalidParent is thrown when replying to a comment that is on a
+	// different post or is itself a reply.
+	errInvalidParent = errors.New("can only reply to a top-level comment on the same post")
+)
+
+// getComments gets n of the top-level comments on a post at a certain
+// offset, each with its replies.
+func (api *API) getComments(ctx *gin.Context) {
+	n, err := strconv.Atoi(ctx.Param("n"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	offset, err := strconv.Atoi(ctx.Param("offset"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	if n > maxCommentPage {
+		n = maxCommentPage
+	}
+
+	post, allowed := api.commentablePost(ctx, ctx.Param("id"))
+	if !allowed {
+		return
+	}
+
+	comments, err := api.database.GetComments(post.PostID, n, offset)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(comments))
+}
+
+// addComment handles a request to comment on a post, or to reply to a
+// comment on a post.
+func (api *API) addComment(ctx *gin.Context) {
+	var request commentRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	post, allowed := api.commentablePost(ctx, ctx.Param("id"))
+	if !allowed {
+		return
+	}
+
+	// Only allow replies to top-level comments on the same post
+	var parent models.Comment
+	if request.ParentID != 0 {
+		parent, err = api.database.GetComment(request.ParentID)
+		if err != nil || parent.PostID != post.PostID || parent.ParentID != 0 {
+			api.check(errInvalidParent, ctx, http.StatusBadRequest)
+			return
+		}
+	}
+
+	if !api.passesFilter(ctx, request.Message) {
+		return
+	}
+
+	comment, err := models.NewComment(
+		post.PostID, viewer(ctx), request.Message, request.ParentID, api.config.Limits,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	err = api.database.AddComment(comment)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.recordEvents(commentEvents(post, parent, *comment)...)
+
+	api.log.Infof("%s commented on post %s", comment.Author, post.PostID)
+	ctx.JSON(http.StatusOK, gr(comment))
+}
+
+// editComment handles a request by the author of a comment to edit it.
+func (api *API) editComment(ctx *gin.Context) {
+	var request commentRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	comment, allowed := api.ownComment(ctx, false)
+	if !allowed {
+		return
+	}
+
+	if !api.passesFilter(ctx, request.Message) {
+		return
+	}
+
+	err = comment.Edit(request.Message, api.config.Limits)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	err = api.database.EditComment(comment.ID, comment.Message, comment.EditedAt)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s edited comment %d", comment.Author, comment.ID)
+	ctx.JSON(http.StatusOK, gr(comment))
+}
+
+// deleteComment handles a request to delete a comment and its replies.
+// Authors can delete their own comments, and moderators can remove any
+// comment.
+func (api *API) deleteComment(ctx *gin.Context) {
+	comment, allowed := api.ownComment(ctx, true)
+	if !allowed {
+		return
+	}
+
+	err := api.database.DeleteComment(comment.ID)
+	if api.check(err, ctx) {
+		return
+	}
+
+	if string(comment.Author) == viewer(ctx) {
+		api.log.Infof("%s deleted comment %d", comment.Author, comment.ID)
+	} else {
+		api.log.Infof("moderator %s removed comment %d by %s",
+			viewer(ctx), comment.ID, comment.Author)
+	}
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// commentablePost gets a post that the requesting user may read and write
+// comments on, which is any post they can see. It writes the error
+// response and returns false if the post can not be commented on.
+func (api *API) commentablePost(
+	ctx *gin.Context,
+	postID string,
+) (models.Post, bool) {
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if err != nil {
+		api.check(errPostNotFound, ctx, http.StatusNotFound)
+		return post, false
+	}
+	return post, true
+}
+
+// ownComment gets the comment in the request if the requesting user wrote
+// it, or if they are a moderator and moderators are allowed. It writes the
+// error response and returns false otherwise.
+func (api *API) ownComment(
+	ctx *gin.Context,
+	allowModerators bool,
+) (models.Comment, bool) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return models.Comment{}, false
+	}
+
+	comment, err := api.database.GetComment(id)
+	if err != nil {
+		api.check(errCommentNotFound, ctx, http.StatusNotFound)
+		return comment, false
+	}
+
+	if string(comment.Author) != viewer(ctx) &&
+		!(allowModerators && api.hasRole(ctx, models.Moderator)) {
+		api.check(errUnauthorized, ctx, http.StatusForbidden)
+		return comment, false
+	}
+
+	return comment, true
+}
+
+// passesFilter runs a message through the content filter. It writes the
+// error response and returns false if the message is rejected.
+func (api *API) passesFilter(ctx *gin.Context, message string) bool {
+	match := api.filter.Check(message)
+	if match == nil {
+		return true
+	}
+
+	api.log.Infof("content filter rejected message by %s (rule %s)",
+		viewer(ctx), match.Rule)
+	api.metrics.filterRejected(filteredComment)
+	ctx.AbortWithStatusJSON(
+		http.StatusUnprocessableEntity,
+		gr(match, errFiltered.Error()),
+	)
+	return false
+}
+
+// commentEvents constructs the in-app notifications telling the sender of
+// a post about a new comment on it, and the author of a comment about a
+// reply to it.
+func commentEvents(
+	post models.Post,
+	parent models.Comment,
+	comment models.Comment,
+) []*models.InAppNotification {
+	var notifs []*models.InAppNotification
+	if post.Sender != comment.Author {
+		notifs = append(notifs, models.NewInAppNotification(
+			post.Sender, commentKind, comment.Author, post.PostID,
+			fmt.Sprintf("%s commented on your post.", comment.Author.Name()),
+		))
+	}
+	if parent.ID != 0 && parent.Author != comment.Author &&
+		parent.Author != post.Sender {
+		notifs = append(notifs, models.NewInAppNotification(
+			parent.Author, commentKind, comment.Author, post.PostID,
+			fmt.Sprintf("%s replied to your comment.", comment.Author.Name()),
+		))
+	}
+	return notifs
+}
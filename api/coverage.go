@@ -0,0 +1,86 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errOnlySeniors is thrown when someone other than a senior tries to be
+// suggested to other users.
+var errOnlySeniors = errors.New("only seniors can be suggested to others")
+
+// getCoverage gets how many posts each senior has received and from how
+// many senders. The max_posts and max_senders queries only keep the
+// seniors at or under them.
+func (api *API) getCoverage(ctx *gin.Context) {
+	maxPosts, err := strconv.Atoi(ctx.DefaultQuery("max_posts", "-1"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	maxSenders, err := strconv.Atoi(ctx.DefaultQuery("max_senders", "-1"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	coverage, err := api.database.GetCoverage(maxPosts, maxSenders)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(coverage))
+}
+
+// writeToSomeoneNew suggests seniors with few posts who the requesting
+// user has not written to yet. Only seniors who opted in are suggested,
+// and their post counts are not shared.
+func (api *API) writeToSomeoneNew(ctx *gin.Context) {
+	limit, err := strconv.Atoi(
+		ctx.DefaultQuery("limit", strconv.Itoa(defaultSuggestions)),
+	)
+	if err != nil || limit <= 0 {
+		api.check(errInvalidLimit, ctx, http.StatusBadRequest)
+		return
+	}
+	if limit > maxSuggestions {
+		limit = maxSuggestions
+	}
+
+	suggestions, err := api.database.SuggestSomeoneNew(viewer(ctx), limit)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(suggestions))
+}
+
+// setOpenToSuggestions handles a request to turn the requesting user's
+// "open to suggestions" setting on or off.
+func (api *API) setOpenToSuggestions(ctx *gin.Context) {
+	var request openToSuggestionsRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	username := viewer(ctx)
+	grade, err := api.database.GetUserGrade(username)
+	if api.check(err, ctx) {
+		return
+	}
+	if request.Enabled && grade != models.Senior {
+		api.check(errOnlySeniors, ctx, http.StatusForbidden)
+		return
+	}
+
+	err = api.database.SetOpenToSuggestions(username, request.Enabled)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s set open to suggestions to %t", username, request.Enabled)
+	ctx.JSON(http.StatusOK, ok())
+}
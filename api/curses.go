@@ -1,19 +0,0 @@
-package api
-
-var curses = []string{
-	"fuck",
-	"shit",
-	"dick",
-	"bitch",
-	"ass",
-	"cunt",
-	"penis",
-	"vagina",
-	"tits",
-	"cock",
-	"nigga",
-	"nigger",
-	"fag",
-	"faggot",
-	"pussy",
-}
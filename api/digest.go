@@ -0,0 +1,107 @@
+package api
+
+import (
+	"time"
+
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// digestInterval is the time between two digests to the same user.
+	digestInterval = 24 * time.Hour
+
+	// digestCheckInterval is how often the digest job looks for users who
+	// are due for a digest.
+	digestCheckInterval = time.Hour
+
+	// excerptLength is the most characters of a message shown in a digest.
+	excerptLength = 140
+)
+
+// sendDigests sends a digest to every user who is due for one.
+func (api *API) sendDigests() error {
+	now := time.Now()
+	subscribers, err := api.database.GetDigestSubscribers(
+		mail.NewPostTemplate, now.Add(-digestInterval),
+	)
+	if err != nil {
+		return err
+	}
+
+	for _, prefs := range subscribers {
+		err = api.sendDigest(prefs, now)
+		if err != nil {
+			api.log.Errorf("could not send digest to %s: %s", prefs.Username, err)
+		}
+	}
+	return nil
+}
+
+// sendDigest sends a user one email listing the inbound posts that went
+// live since their last digest, and moves their watermark forward to now.
+func (api *API) sendDigest(prefs models.NotificationPreferences, now time.Time) error {
+	since := prefs.LastDigest
+	if since.IsZero() {
+		since = now.Add(-digestInterval)
+	}
+
+	inbound, err := api.database.GetUserInbound(
+		string(prefs.Username), prefs.Username,
+	)
+	if err != nil {
+		return err
+	}
+
+	var posts []mail.DigestPost
+	for _, post := range inbound {
+		// Posts from before live times were recorded went live when sent
+		live := post.LiveAt
+		if live.IsZero() {
+			live = post.Timestamp
+		}
+		if live.After(since) && !live.After(now) {
+			posts = append(posts, mail.DigestPost{
+				Sender:  post.Sender.Name(),
+				Excerpt: excerpt(post.Message, excerptLength),
+				PostURL: api.postURL(post.PostID),
+			})
+		}
+	}
+
+	// Nothing new, so just move the watermark
+	if len(posts) == 0 {
+		return api.database.RecordDigest(prefs.Username, now)
+	}
+
+	unsubscribe, err := api.unsubscribeURL(prefs.Username, mail.NewPostTemplate)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.Render(mail.DigestTemplate, mail.DigestData{
+		Footer:  mail.Footer{UnsubscribeURL: unsubscribe},
+		Name:    prefs.Username.Name(),
+		Posts:   posts,
+		SiteURL: api.siteURL(),
+	})
+	if err != nil {
+		return err
+	}
+	notif := models.NewNotification(
+		mail.DigestTemplate, []string{prefs.Username.Email()},
+		msg.Subject, msg.Text, msg.HTML,
+	)
+	notif.Unsubscribe = unsubscribe
+
+	api.log.Infof("sending digest of %d posts to %s", len(posts), prefs.Username)
+	return api.database.RecordDigest(prefs.Username, now, notif)
+}
+
+// excerpt shortens a message to at most n characters.
+func excerpt(message string, n int) string {
+	runes := []rune(message)
+	if len(runes) <= n {
+		return message
+	}
+	return string(runes[:n-1]) + "…"
+}
@@ -0,0 +1,54 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+)
+
+const (
+	// defaultSuggestions is the number of users suggested if no limit is
+	// given.
+	defaultSuggestions = 10
+
+	// maxSuggestions is the most users suggested in one request.
+	maxSuggestions = 50
+)
+
+// errInvalidLimit is thrown when the number of users to suggest is not a
+// positive number.
+var errInvalidLimit = errors.New("limit must be a positive number")
+
+// suggestUsers suggests recipients whose names or username match a query,
+// optionally only in one grade.
+func (api *API) suggestUsers(ctx *gin.Context) {
+	limit, err := strconv.Atoi(
+		ctx.DefaultQuery("limit", strconv.Itoa(defaultSuggestions)),
+	)
+	if err != nil || limit <= 0 {
+		api.check(errInvalidLimit, ctx, http.StatusBadRequest)
+		return
+	}
+	if limit > maxSuggestions {
+		limit = maxSuggestions
+	}
+
+	grade := -1
+	if ctx.Query("grade") != "" {
+		grade, err = strconv.Atoi(ctx.Query("grade"))
+		if api.check(err, ctx, http.StatusBadRequest) {
+			return
+		}
+	}
+
+	suggestions, err := api.database.SuggestUsers(
+		ctx.Query("q"), limit, grade, viewer(ctx),
+	)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(suggestions))
+}
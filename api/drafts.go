@@ -0,0 +1,222 @@
+package api
+
+import (
+	"encoding/base64"
+	"errors"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// scheduleCheckInterval is how often the scheduler looks for scheduled
+// posts that are due to be published.
+const scheduleCheckInterval = time.Minute
+
+// errDraftNotFound is thrown when a draft or scheduled post does not exist
+// or belongs to someone else.
+var errDraftNotFound = errors.New("draft not found")
+
+// errInvalidSchedule is thrown when editing a draft asks for conflicting
+// things, or schedules it in the past.
+var errInvalidSchedule = errors.New(
+	"a draft can be published, unscheduled, or scheduled for a future time",
+)
+
+// getDrafts gets the drafts and scheduled posts of the requesting user.
+func (api *API) getDrafts(ctx *gin.Context) {
+	posts, err := api.database.GetDrafts(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(posts))
+}
+
+// updateDraft handles a request to edit a draft or scheduled post. Only
+// the fields in the request are changed, and the post is only published if
+// the request asks for it.
+func (api *API) updateDraft(ctx *gin.Context) {
+	var request updateDraftRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	unschedule := request.Draft != nil && *request.Draft
+	if request.Publish && (unschedule || request.PublishAt != nil) ||
+		unschedule && request.PublishAt != nil ||
+		request.PublishAt != nil && !request.PublishAt.After(time.Now()) {
+		api.check(errInvalidSchedule, ctx, http.StatusBadRequest)
+		return
+	}
+
+	username := viewer(ctx)
+	post, err := api.database.GetPost(ctx.Param("id"), api.viewerOf(ctx))
+	if err != nil || string(post.Sender) != username || !post.Unpublished() {
+		api.check(errDraftNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	// Fill in the fields that are not being changed
+	message, recipients, images := post.Message, recipientStrings(post), imageStrings(post)
+	if request.Message != nil {
+		message = *request.Message
+	}
+	if request.Recipients != nil {
+		recipients = *request.Recipients
+	}
+	if request.Images != nil {
+		images = *request.Images
+	}
+	if request.Visibility != nil {
+		post.Visibility, err = models.ParseVisibility(*request.Visibility)
+		if api.check(err, ctx, http.StatusBadRequest) {
+			return
+		}
+	}
+
+	// Check the new contents the same way as a new post
+	var mentions []models.Mention
+	var warnings []string
+	recipients, mentions, warnings, err = api.mentionRecipients(
+		username, message, recipients,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	recipients, err = api.allowedRecipients(username, recipients)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	flagged, allowed := api.filterPost(ctx, username, message)
+	if !allowed {
+		return
+	}
+	edited, err := models.NewPost(
+		username, message, images, recipients, api.config.Limits,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	post.Recipients = edited.Recipients
+	post.Message = edited.Message
+	post.Images = edited.Images
+	post.Flagged = flagged
+	post.Mentions = mentions
+
+	switch {
+	case unschedule:
+		post.Status = models.Draft
+		post.PublishAt = time.Time{}
+	case request.PublishAt != nil:
+		post.Status = models.Scheduled
+		post.PublishAt = *request.PublishAt
+	}
+	if request.Publish {
+		err = api.release(&post, true)
+	} else {
+		err = api.database.UpdateDraft(&post)
+	}
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s edited unpublished post %s", username, post.PostID)
+	response := createPostResponse{post.PostID, mentions, warnings}
+	ctx.JSON(http.StatusOK, gr(response))
+}
+
+// deleteDraft handles a request to delete a draft or scheduled post.
+func (api *API) deleteDraft(ctx *gin.Context) {
+	postID := ctx.Param("id")
+
+	err := api.database.DeleteDraft(postID, viewer(ctx))
+	if err != nil {
+		api.check(errDraftNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	api.log.Infof("%s deleted unpublished post %s", viewer(ctx), postID)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// publishDuePosts is the job that publishes the scheduled posts that are
+// due. The recipients and the content filter are checked again, since
+// they may have changed since the post was scheduled.
+func (api *API) publishDuePosts() error {
+	posts, err := api.database.GetDuePosts(time.Now())
+	if err != nil {
+		return err
+	}
+
+	for i := range posts {
+		post := &posts[i]
+
+		// Send the post back to the sender's drafts if it can no longer
+		// be sent to anyone
+		recipients, err := api.allowedRecipients(
+			string(post.Sender), recipientStrings(*post),
+		)
+		if err != nil {
+			api.log.Warningf("returning scheduled post %s to drafts: %s",
+				post.PostID, err)
+			post.Status = models.Draft
+			err = api.database.UpdateDraft(post)
+			if err != nil {
+				api.log.Errorf("could not return post %s to drafts: %s",
+					post.PostID, err)
+			}
+			continue
+		}
+		post.Recipients = []models.Username{}
+		for _, recipient := range recipients {
+			post.Recipients = append(post.Recipients, models.Username(recipient))
+		}
+
+		// Flagged posts are held for a moderator
+		post.Flagged = api.filter.Check(post.Message) != nil
+
+		err = api.release(post, true)
+		if err != nil {
+			api.log.Errorf("could not publish scheduled post %s: %s",
+				post.PostID, err)
+			continue
+		}
+		api.log.Infof("published scheduled post %s", post.PostID)
+	}
+	return nil
+}
+
+// unpublishedStatus gets the status of a post that is saved as a draft or
+// scheduled for later. It returns false if the post should be published
+// right away.
+func unpublishedStatus(draft bool, publishAt time.Time) (models.Status, bool) {
+	if draft {
+		return models.Draft, true
+	}
+	if publishAt.After(time.Now()) {
+		return models.Scheduled, true
+	}
+	return 0, false
+}
+
+// imageStrings gets the images of a post in base64.
+func imageStrings(post models.Post) []string {
+	var images []string
+	for _, image := range post.Images {
+		images = append(images, base64.StdEncoding.EncodeToString(image))
+	}
+	return images
+}
+
+// recipientStrings gets the usernames of the recipients of a post as
+// strings.
+func recipientStrings(post models.Post) []string {
+	var recipients []string
+	for _, recipient := range post.Recipients {
+		recipients = append(recipients, string(recipient))
+	}
+	return recipients
+}
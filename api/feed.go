@@ -0,0 +1,136 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// maxFeedPage is the most in-app notifications returned in one page.
+const maxFeedPage = 100
+
+// commentKind is the kind of in-app notification about a comment. Comments
+// have no email template, so it is not a template name like the others.
+const commentKind = "comment"
+
+// recordEvents adds in-app notifications to users' feeds after an action
+// succeeds, logging if it fails.
+func (api *API) recordEvents(notifs ...*models.InAppNotification) {
+	err := api.database.AddInAppNotifications(notifs...)
+	if err != nil {
+		api.log.Errorf("could not record in-app notifications: %s", err)
+		return
+	}
+
+	// Push them to the users' open event streams
+	for _, notif := range notifs {
+		api.hub.publish(notificationEvent, string(notif.Username), notif)
+	}
+}
+
+// newPostEvents constructs the in-app notifications telling the recipients
+// of a live post that they have been congratulated.
+func newPostEvents(post models.Post) []*models.InAppNotification {
+	var notifs []*models.InAppNotification
+	for _, recipient := range post.Recipients {
+		notifs = append(notifs, models.NewInAppNotification(
+			recipient, mail.NewPostTemplate, post.Sender, post.PostID,
+			fmt.Sprintf("%s congratulated you!", post.Sender.Name()),
+		))
+	}
+	return notifs
+}
+
+// moderationEvent constructs the in-app notification telling the sender
+// of a post whether it was approved.
+func moderationEvent(
+	post models.Post,
+	moderator models.Username,
+	approved bool,
+) *models.InAppNotification {
+	message := "Your post was not approved."
+	if approved {
+		message = "Your post was approved and is now live!"
+	}
+	return models.NewInAppNotification(
+		post.Sender, mail.ModerationTemplate, moderator, post.PostID, message,
+	)
+}
+
+// recipientActionEvent constructs the in-app notification telling the
+// sender of a post that a recipient hid it or removed themselves from it.
+func recipientActionEvent(
+	post models.Post,
+	recipient models.Username,
+	action string,
+) *models.InAppNotification {
+	return models.NewInAppNotification(
+		post.Sender, mail.RecipientActionTemplate, recipient, post.PostID,
+		fmt.Sprintf("%s %s your post.", recipient.Name(), action),
+	)
+}
+
+// getNotifications gets n of the requesting user's in-app notifications
+// at a certain offset.
+func (api *API) getNotifications(ctx *gin.Context) {
+	n, err := strconv.Atoi(ctx.Param("n"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	offset, err := strconv.Atoi(ctx.Param("offset"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	if n > maxFeedPage {
+		n = maxFeedPage
+	}
+
+	notifs, err := api.database.GetInAppNotifications(viewer(ctx), n, offset)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(notifs))
+}
+
+// getUnreadCount gets the number of unread in-app notifications of the
+// requesting user.
+func (api *API) getUnreadCount(ctx *gin.Context) {
+	n, err := api.database.GetUnreadCount(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(n))
+}
+
+// markNotificationRead handles a request to mark an in-app notification
+// read.
+func (api *API) markNotificationRead(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	err = api.database.MarkNotificationRead(viewer(ctx), id)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// markAllNotificationsRead handles a request to mark all of the requesting
+// user's in-app notifications read.
+func (api *API) markAllNotificationsRead(ctx *gin.Context) {
+	err := api.database.MarkAllNotificationsRead(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, ok())
+}
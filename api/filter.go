@@ -0,0 +1,114 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/filter"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errInvalidFilterWord is thrown when a filter word request is malformed.
+var errInvalidFilterWord = errors.New("invalid filter word or list")
+
+// reloadFilter loads the content filter lists from the database into the
+// API's content filter.
+func (api *API) reloadFilter() error {
+	words, err := api.database.GetFilterWords()
+	if err != nil {
+		return err
+	}
+
+	var deny, allow []string
+	for _, word := range words {
+		switch word.List {
+		case models.DenyList:
+			deny = append(deny, word.Word)
+		case models.AllowList:
+			allow = append(allow, word.Word)
+		}
+	}
+
+	// Never run without a deny list
+	if len(deny) == 0 {
+		api.log.Warningf("no deny list in the database, using the default")
+		deny = filter.DefaultDenyList
+	}
+
+	// Only filters with editable lists can be reloaded
+	if f, ok := api.filter.(interface{ SetLists(deny, allow []string) }); ok {
+		f.SetLists(deny, allow)
+	}
+
+	api.log.Infof("loaded content filter (%d denied, %d allowed)",
+		len(deny), len(allow))
+	return nil
+}
+
+// getFilterWords gets the content filter lists.
+func (api *API) getFilterWords(ctx *gin.Context) {
+	words, err := api.database.GetFilterWords()
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(words))
+}
+
+// addFilterWord handles a request to add a word to a content filter list.
+func (api *API) addFilterWord(ctx *gin.Context) {
+	var request filterWordRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	// Validate the request
+	list := models.FilterList(request.List)
+	word := strings.ToLower(strings.TrimSpace(request.Word))
+	if strings.Trim(word, "*") == "" ||
+		(list != models.DenyList && list != models.AllowList) {
+		api.check(errInvalidFilterWord, ctx, http.StatusBadRequest)
+		return
+	}
+
+	err = api.database.AddFilterWord(&models.FilterWord{
+		Word:      word,
+		List:      list,
+		AddedBy:   models.Username(viewer(ctx)),
+		Timestamp: time.Now(),
+	})
+	if api.check(err, ctx) {
+		return
+	}
+
+	err = api.reloadFilter()
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s added '%s' to the %s list", viewer(ctx), word, list)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// deleteFilterWord handles a request to delete a word from the content
+// filter lists.
+func (api *API) deleteFilterWord(ctx *gin.Context) {
+	word := ctx.Param("word")
+
+	err := api.database.DeleteFilterWord(word)
+	if api.check(err, ctx) {
+		return
+	}
+
+	err = api.reloadFilter()
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s removed '%s' from the content filter", viewer(ctx), word)
+	ctx.JSON(http.StatusOK, ok())
+}
@@ -2,6 +2,8 @@ package api
 
 import (
 	"encoding/json"
+	"time"
+
 	"github.com/mattnappo/yearbook/models"
 )
 
@@ -16,6 +18,84 @@ type createPostRequest struct {
 	Recipients []string `json:"recipients"` // In the form first.last
 	Message    string   `json:"message"`    // Just a regular string
 	Images     []string `json:"images"`     // Slice of images in base64
+	Visibility string   `json:"visibility"` // Public if left out
+
+	Draft     bool      `json:"draft"`      // Save without publishing
+	PublishAt time.Time `json:"publish_at"` // Publish later, if given
+}
+
+// updateDraftRequest is the structure of a request to edit a draft or
+// scheduled post. The fields that are left out are not changed. Draft
+// unschedules the post, a PublishAt time schedules it, and the post is only
+// published right away if Publish is set.
+type updateDraftRequest struct {
+	Recipients *[]string `json:"recipients"`
+	Message    *string   `json:"message"`
+	Images     *[]string `json:"images"`
+	Visibility *string   `json:"visibility"`
+
+	Draft     *bool      `json:"draft"`
+	PublishAt *time.Time `json:"publish_at"`
+	Publish   bool       `json:"publish"`
+}
+
+// createPostResponse is the response of a request to create a post or
+// edit a draft.
+type createPostResponse struct {
+	PostID   string           `json:"post_id"`
+	Mentions []models.Mention `json:"mentions"`
+	Warnings []string         `json:"warnings"` // About mentions left out
+}
+
+// moderatePostRequest is the structure of a request to approve or reject
+// a post.
+type moderatePostRequest struct {
+	Reason string `json:"reason"`
+}
+
+// filterWordRequest is the structure of a request to add a word to a
+// content filter list.
+type filterWordRequest struct {
+	Word string `json:"word"`
+	List string `json:"list"` // Either "deny" or "allow"
+}
+
+// reportPostRequest is the structure of a request to report a post.
+type reportPostRequest struct {
+	Category string `json:"category"` // One of the report categories
+	Reason   string `json:"reason"`   // Free text
+}
+
+// reviewReportRequest is the structure of a request to resolve or dismiss
+// a report.
+type reviewReportRequest struct {
+	Note   string `json:"note"`
+	Remove bool   `json:"remove"` // Also remove the post when resolving
+}
+
+// doNotAcceptPostsRequest is the structure of a request to change the
+// "do not accept posts" setting.
+type doNotAcceptPostsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// openToSuggestionsRequest is the structure of a request to change the
+// "open to suggestions" setting.
+type openToSuggestionsRequest struct {
+	Enabled bool `json:"enabled"`
+}
+
+// updatePreferencesRequest is the structure of a request to update
+// notification preferences. Fields that are left out are not changed.
+type updatePreferencesRequest struct {
+	EmailEnabled *bool                     `json:"email_enabled"`
+	Cadences     map[string]models.Cadence `json:"cadences"`
+}
+
+// commentRequest is the structure of a request to add or edit a comment.
+type commentRequest struct {
+	Message  string `json:"message"`
+	ParentID int64  `json:"parent_id"` // The comment being replied to, if any
 }
 
 type authorizeRequest struct {
@@ -0,0 +1,56 @@
+package api
+
+import (
+	"sync"
+	"time"
+
+	"github.com/juju/loggo"
+)
+
+// jobs runs the API's background jobs on an interval until it is stopped.
+type jobs struct {
+	log  *loggo.Logger
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newJobs constructs a new *jobs.
+func newJobs(log *loggo.Logger) *jobs {
+	return &jobs{
+		log:  log,
+		quit: make(chan struct{}),
+	}
+}
+
+// every runs a job once right away and then on every interval. Errors are
+// logged, and the job keeps running.
+func (j *jobs) every(name string, interval time.Duration, job func() error) {
+	j.wg.Add(1)
+	go func() {
+		defer j.wg.Done()
+
+		ticker := time.NewTicker(interval)
+		defer ticker.Stop()
+
+		for {
+			err := job()
+			if err != nil {
+				j.log.Errorf("%s job failed: %s", name, err)
+			}
+
+			select {
+			case <-ticker.C:
+			case <-j.quit:
+				return
+			}
+		}
+	}()
+	j.log.Infof("scheduled %s job every %s", name, interval)
+}
+
+// stop stops every job, waiting for running jobs to finish.
+func (j *jobs) stop() {
+	close(j.quit)
+	j.wg.Wait()
+	j.log.Infof("stopped background jobs")
+}
@@ -0,0 +1,81 @@
+package api
+
+import (
+	"fmt"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+// mentionRecipients finds the mentions in the message of a post and adds
+// the mentioned users to its recipients. Mentions of users who do not
+// exist are left out, and mentioned users who do not accept posts are not
+// added as recipients. Both are reported as warnings.
+func (api *API) mentionRecipients(
+	sender string,
+	message string,
+	recipients []string,
+) ([]string, []models.Mention, []string, error) {
+	mentions, warnings := []models.Mention{}, []string{}
+	parsed := models.ParseMentions(message)
+	if len(parsed) == 0 {
+		return recipients, mentions, warnings, nil
+	}
+
+	// Look up the mentioned users in the user directory
+	var usernames []string
+	for _, mention := range parsed {
+		usernames = append(usernames, string(mention.Username))
+	}
+	users, err := api.database.GetUsers(usernames)
+	if err != nil {
+		return nil, nil, nil, err
+	}
+	known := make(map[models.Username]models.User)
+	for _, user := range users {
+		known[user.Username] = user
+	}
+
+	added := make(map[string]bool)
+	for _, recipient := range recipients {
+		added[recipient] = true
+	}
+	warned := make(map[models.Username]bool)
+	for _, mention := range parsed {
+		username := mention.Username
+		user, ok := known[username]
+		if !ok {
+			if !warned[username] {
+				warnings = append(warnings,
+					fmt.Sprintf("@%s is not a known user", username))
+				warned[username] = true
+			}
+			continue
+		}
+
+		mentions = append(mentions, mention)
+		if string(username) == sender || added[string(username)] {
+			continue
+		}
+		if user.DoNotAcceptPosts {
+			if !warned[username] {
+				warnings = append(warnings, fmt.Sprintf(
+					"@%s is not accepting posts and was not added as a recipient",
+					username,
+				))
+				warned[username] = true
+			}
+			continue
+		}
+
+		recipients = append(recipients, string(username))
+		added[string(username)] = true
+	}
+
+	if len(recipients) > api.config.Limits.MaxRecipients {
+		return nil, nil, nil, fmt.Errorf(
+			"a post can have at most %d recipients, including mentions",
+			api.config.Limits.MaxRecipients,
+		)
+	}
+	return recipients, mentions, warnings, nil
+}
@@ -1,15 +1,25 @@
 package api
 
 import (
+	"errors"
 	"net/http"
 	"strconv"
-	"strings"
+	"time"
 
 	"github.com/gin-gonic/gin"
 	"github.com/mattnappo/yearbook/common"
 	"github.com/mattnappo/yearbook/models"
 )
 
+var (
+	// errPostNotFound is thrown when a post does not exist or cannot be
+	// seen.
+	errPostNotFound = errors.New("post not found")
+
+	// errFiltered is thrown when the content filter rejects a message.
+	errFiltered = errors.New("message rejected by the content filter")
+)
+
 // createPost creates a new post.
 func (api *API) createPost(ctx *gin.Context) {
 	// Decode the post request
@@ -26,13 +36,28 @@ func (api *API) createPost(ctx *gin.Context) {
 		return
 	}
 
-	// Make sure that there are no curse words
-	lowerMessage := strings.ToLower(request.Message)
-	for _, curse := range curses {
-		if strings.Contains(lowerMessage, curse) {
-			ctx.JSON(http.StatusOK, gr(nil, "curse word"))
-			return
-		}
+	// Add the mentioned users to the recipients
+	var mentions []models.Mention
+	var warnings []string
+	request.Recipients, mentions, warnings, err = api.mentionRecipients(
+		request.Sender, request.Message, request.Recipients,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	// Drop the recipients who blocked the sender
+	request.Recipients, err = api.allowedRecipients(
+		request.Sender, request.Recipients,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	// Run the message through the content filter
+	flagged, allowed := api.filterPost(ctx, request.Sender, request.Message)
+	if !allowed {
+		return
 	}
 
 	// Create the new post
@@ -41,22 +66,108 @@ func (api *API) createPost(ctx *gin.Context) {
 		request.Message,
 		request.Images,
 		request.Recipients,
+		api.config.Limits,
 	)
 	if api.check(err, ctx) {
 		return
 	}
+	post.Visibility, err = models.ParseVisibility(request.Visibility)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+	post.Flagged = flagged
+	post.Mentions = mentions
+	response := createPostResponse{post.PostID, mentions, warnings}
 
-	// Add it to the database
-	err = api.database.AddPost(post)
+	// Save drafts and scheduled posts without publishing them
+	if status, saved := unpublishedStatus(request.Draft, request.PublishAt); saved {
+		post.Status = status
+		post.PublishAt = request.PublishAt
+		err = api.database.AddPost(post)
+		if api.check(err, ctx) {
+			return
+		}
+
+		api.log.Infof("saved unpublished post %s", post.PostID)
+		ctx.JSON(http.StatusOK, gr(response))
+		return
+	}
+
+	err = api.release(post, false)
 	if api.check(err, ctx) {
 		return
 	}
 
+	api.log.Infof("created new post %s", post.PostID)
+	ctx.JSON(http.StatusOK, gr(response))
+}
+
+// filterPost runs the message of a post through the content filter. Under
+// the hold-flagged policy, flagged posts are held for review instead of
+// rejected. It writes the error response and returns false if the post is
+// rejected.
+func (api *API) filterPost(
+	ctx *gin.Context,
+	sender string,
+	message string,
+) (flagged bool, allowed bool) {
+	match := api.filter.Check(message)
+	if match != nil && api.config.Moderation != common.HoldFlagged {
+		api.log.Infof("content filter rejected post by %s (rule %s)",
+			sender, match.Rule)
+		api.metrics.filterRejected(filteredPost)
+		ctx.AbortWithStatusJSON(
+			http.StatusUnprocessableEntity,
+			gr(match, errFiltered.Error()),
+		)
+		return true, false
+	}
+	return match != nil, true
+}
+
+// release publishes a new post or a draft. The post goes live right away
+// unless the moderation policy holds it for review, or it was flagged by
+// the content filter. It is saved along with the emails about it, and then
+// delivered to its recipients.
+func (api *API) release(post *models.Post, draft bool) error {
+	post.Status = models.Approved
+	if api.config.Moderation == common.HoldAll || post.Flagged {
+		post.Status = models.Pending
+	} else {
+		post.LiveAt = time.Now()
+	}
+
+	// Email the recipients if the post is live, otherwise let the
+	// moderators know that there is a post to review. The emails are
+	// added to the outbox along with the post.
+	var notifs []*models.Notification
+	var err error
+	if post.Status == models.Approved {
+		notifs, err = api.newPostNotifications(*post)
+	} else {
+		notifs, err = api.modReviewNotifications(*post)
+	}
+	if err != nil {
+		return err
+	}
+
+	// Add it to the database
+	if draft {
+		post.Timestamp = time.Now()
+		err = api.database.PublishDraft(post, notifs...)
+	} else {
+		err = api.database.AddPost(post, notifs...)
+	}
+	if err != nil {
+		return err
+	}
+	api.metrics.postCreated()
+
 	// Add the recipients to the database (if they do not already exist)
 	for _, recip := range post.Recipients {
 		newUser, err := models.NewUser(recip.Email(), models.Senior, false)
-		if api.check(err, ctx) {
-			return
+		if err != nil {
+			return err
 		}
 		err = api.database.AddUser(newUser) // Unhandled err
 	}
@@ -65,38 +176,47 @@ func (api *API) createPost(ctx *gin.Context) {
 	err = api.database.AddToAndFrom(
 		post.PostID,
 		string(post.Sender),
-		request.Recipients,
+		recipientStrings(*post),
 	)
-	if api.check(err, ctx) {
-		return
+	if err != nil {
+		return err
 	}
 
-	// Send an email notification
-	if common.NotifsEnabled {
-		go api.sendNotification(post.Sender, post.Recipients)
+	if post.Status == models.Approved {
+		api.publishPost(*post)
+		api.recordEvents(newPostEvents(*post)...)
 	}
-
-	go api.sendModEmail(*post)
-
-	api.log.Infof("created new post %s", post.PostID)
-	ctx.JSON(http.StatusOK, ok())
+	return nil
 }
 
 // getPost gets a post.
 func (api *API) getPost(ctx *gin.Context) {
 	id := ctx.Param("id")
 
-	post, err := api.database.GetPost(id)
+	// Posts that the user can not see do not exist as far as they know
+	post, err := api.database.GetPost(id, api.viewerOf(ctx))
+	if err != nil {
+		api.check(errPostNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	posts := []models.Post{post}
+	err = api.database.AttachReactions(posts, viewer(ctx))
 	if api.check(err, ctx) {
 		return
 	}
 
-	ctx.JSON(http.StatusOK, gr(post))
+	ctx.JSON(http.StatusOK, gr(posts[0]))
 }
 
 // getPosts gets all posts.
 func (api *API) getPosts(ctx *gin.Context) {
-	posts, err := api.database.GetAllPosts()
+	posts, err := api.database.GetAllPosts(models.Username(viewer(ctx)))
+	if api.check(err, ctx) {
+		return
+	}
+
+	err = api.database.AttachReactions(posts, viewer(ctx))
 	if api.check(err, ctx) {
 		return
 	}
@@ -113,7 +233,12 @@ func (api *API) getnPosts(ctx *gin.Context) {
 		return
 	}
 
-	posts, err := api.database.GetnPosts(nInt)
+	posts, err := api.database.GetnPosts(nInt, models.Username(viewer(ctx)))
+	if api.check(err, ctx) {
+		return
+	}
+
+	err = api.database.AttachReactions(posts, viewer(ctx))
 	if api.check(err, ctx) {
 		return
 	}
@@ -123,7 +248,7 @@ func (api *API) getnPosts(ctx *gin.Context) {
 
 // getNumPosts gets the number of posts in the database
 func (api *API) getNumPosts(ctx *gin.Context) {
-	n, err := api.database.GetNumPosts()
+	n, err := api.database.GetNumPosts(models.Username(viewer(ctx)))
 	if api.check(err, ctx) {
 		return
 	}
@@ -140,7 +265,14 @@ func (api *API) getnPostsOffset(ctx *gin.Context) {
 		return
 	}
 
-	posts, err := api.database.GetnPostsWithOffset(nInt, offsetInt)
+	posts, err := api.database.GetnPostsWithOffset(
+		nInt, offsetInt, models.Username(viewer(ctx)),
+	)
+	if api.check(err, ctx) {
+		return
+	}
+
+	err = api.database.AttachReactions(posts, viewer(ctx))
 	if api.check(err, ctx) {
 		return
 	}
@@ -165,10 +297,17 @@ func (api *API) deletePost(ctx *gin.Context) {
 		return
 	}
 
+	// Get the post first to know who may hear that it was deleted
+	post, err := api.database.GetPost(postID, models.Viewer{Moderator: true})
+	if api.check(err, ctx) {
+		return
+	}
+
 	err = api.database.DeletePost(postID)
 	if api.check(err, ctx) {
 		return
 	}
+	api.publishPostDeleted(post)
 
 	api.log.Infof("deleted post %s", postID)
 	ctx.JSON(http.StatusOK, ok())
@@ -306,7 +445,9 @@ func (api *API) getActivity(ctx *gin.Context) {
 	}
 
 	// Get the inbound posts
-	inboundPosts, err := api.database.GetUserInbound(username)
+	inboundPosts, err := api.database.GetUserInbound(
+		username, models.Username(viewer(ctx)),
+	)
 	if api.check(err, ctx) {
 		return
 	}
@@ -327,11 +468,20 @@ func (api *API) getActivity(ctx *gin.Context) {
 func (api *API) getUserPosts(ctx *gin.Context) {
 	username := ctx.Param("username")
 
-	posts, err := api.database.GetUserInboundOutbound(username)
+	posts, err := api.database.GetUserInboundOutbound(
+		username, models.Username(viewer(ctx)),
+	)
 	if api.check(err, ctx) {
 		return
 	}
 
+	for _, list := range posts {
+		err = api.database.AttachReactions(list, viewer(ctx))
+		if api.check(err, ctx) {
+			return
+		}
+	}
+
 	res := inboundOutboundResponse{
 		Inbound:  posts[0],
 		Outbound: posts[1],
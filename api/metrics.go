@@ -0,0 +1,337 @@
+package api
+
+import (
+	"crypto/subtle"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+)
+
+// metricsPath is the path that metrics are served on.
+const metricsPath = "/metrics"
+
+// The kinds of messages rejected by the content filter.
+const (
+	filteredPost    = "post"
+	filteredComment = "comment"
+)
+
+// latencyBuckets are the upper bounds, in seconds, of the buckets of the
+// latency histograms.
+var latencyBuckets = []float64{
+	.005, .01, .025, .05, .1, .25, .5, 1, 2.5, 5, 10,
+}
+
+// requestKey identifies the requests counted together.
+type requestKey struct {
+	method string
+	route  string
+	status int
+}
+
+// routeKey identifies the requests timed together.
+type routeKey struct {
+	method string
+	route  string
+}
+
+// histogram counts observations in buckets.
+type histogram struct {
+	counts []uint64 // The observations in each bucket, not cumulative
+	sum    float64
+	count  uint64
+}
+
+// observe adds an observation to the histogram.
+func (h *histogram) observe(v float64) {
+	if h.counts == nil {
+		h.counts = make([]uint64, len(latencyBuckets))
+	}
+	for i, bound := range latencyBuckets {
+		if v <= bound {
+			h.counts[i]++
+			break
+		}
+	}
+	h.sum += v
+	h.count++
+}
+
+// write writes the histogram in the Prometheus text format.
+func (h *histogram) write(w io.Writer, name string, labels []string) {
+	var cumulative uint64
+	for i, bound := range latencyBuckets {
+		if h.counts != nil {
+			cumulative += h.counts[i]
+		}
+		le := strconv.FormatFloat(bound, 'g', -1, 64)
+		fmt.Fprintf(w, "%s_bucket%s %d\n",
+			name, formatLabels(append(labels, "le", le)...), cumulative)
+	}
+	fmt.Fprintf(w, "%s_bucket%s %d\n",
+		name, formatLabels(append(labels, "le", "+Inf")...), h.count)
+	fmt.Fprintf(w, "%s_sum%s %g\n", name, formatLabels(labels...), h.sum)
+	fmt.Fprintf(w, "%s_count%s %d\n", name, formatLabels(labels...), h.count)
+}
+
+// metrics collects the metrics of the API.
+type metrics struct {
+	mux sync.Mutex
+
+	requests         map[requestKey]uint64
+	requestDurations map[routeKey]*histogram
+	postsCreated     uint64
+	notifsSent       uint64
+	notifsFailed     uint64
+	filterRejections map[string]uint64 // By the kind of message
+	userinfoDuration histogram
+}
+
+// newMetrics constructs a new *metrics.
+func newMetrics() *metrics {
+	return &metrics{
+		requests:         make(map[requestKey]uint64),
+		requestDurations: make(map[routeKey]*histogram),
+		filterRejections: make(map[string]uint64),
+	}
+}
+
+// observeRequest records a handled request.
+func (m *metrics) observeRequest(
+	method string,
+	route string,
+	status int,
+	duration time.Duration,
+) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	m.requests[requestKey{method, route, status}]++
+	key := routeKey{method, route}
+	if m.requestDurations[key] == nil {
+		m.requestDurations[key] = &histogram{}
+	}
+	m.requestDurations[key].observe(duration.Seconds())
+}
+
+// postCreated counts a post that was published, whether it was created
+// that way or was a draft or scheduled post.
+func (m *metrics) postCreated() {
+	m.mux.Lock()
+	m.postsCreated++
+	m.mux.Unlock()
+}
+
+// notificationSent counts an attempt to send an email notification.
+func (m *metrics) notificationSent(err error) {
+	m.mux.Lock()
+	if err == nil {
+		m.notifsSent++
+	} else {
+		m.notifsFailed++
+	}
+	m.mux.Unlock()
+}
+
+// filterRejected counts a message rejected by the content filter.
+func (m *metrics) filterRejected(kind string) {
+	m.mux.Lock()
+	m.filterRejections[kind]++
+	m.mux.Unlock()
+}
+
+// observeUserinfo records the latency of a Google userinfo call.
+func (m *metrics) observeUserinfo(duration time.Duration) {
+	m.mux.Lock()
+	m.userinfoDuration.observe(duration.Seconds())
+	m.mux.Unlock()
+}
+
+// write writes the metrics in the Prometheus text format.
+func (m *metrics) write(w io.Writer) {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	writeHeader(w, "yearbook_http_requests_total", "counter",
+		"The number of HTTP requests handled.")
+	requests := make([]requestKey, 0, len(m.requests))
+	for key := range m.requests {
+		requests = append(requests, key)
+	}
+	sort.Slice(requests, func(i, j int) bool {
+		a, b := requests[i], requests[j]
+		if a.route != b.route {
+			return a.route < b.route
+		}
+		if a.method != b.method {
+			return a.method < b.method
+		}
+		return a.status < b.status
+	})
+	for _, key := range requests {
+		fmt.Fprintf(w, "yearbook_http_requests_total%s %d\n", formatLabels(
+			"method", key.method,
+			"route", key.route,
+			"status", strconv.Itoa(key.status),
+		), m.requests[key])
+	}
+
+	writeHeader(w, "yearbook_http_request_duration_seconds", "histogram",
+		"The time taken to handle HTTP requests.")
+	routes := make([]routeKey, 0, len(m.requestDurations))
+	for key := range m.requestDurations {
+		routes = append(routes, key)
+	}
+	sort.Slice(routes, func(i, j int) bool {
+		if routes[i].route != routes[j].route {
+			return routes[i].route < routes[j].route
+		}
+		return routes[i].method < routes[j].method
+	})
+	for _, key := range routes {
+		m.requestDurations[key].write(w, "yearbook_http_request_duration_seconds",
+			[]string{"method", key.method, "route", key.route})
+	}
+
+	writeHeader(w, "yearbook_posts_created_total", "counter",
+		"The number of posts published.")
+	fmt.Fprintf(w, "yearbook_posts_created_total %d\n", m.postsCreated)
+
+	writeHeader(w, "yearbook_notifications_sent_total", "counter",
+		"The number of email notifications sent.")
+	fmt.Fprintf(w, "yearbook_notifications_sent_total %d\n", m.notifsSent)
+
+	writeHeader(w, "yearbook_notifications_failed_total", "counter",
+		"The number of attempts to send an email notification that failed.")
+	fmt.Fprintf(w, "yearbook_notifications_failed_total %d\n", m.notifsFailed)
+
+	writeHeader(w, "yearbook_filter_rejections_total", "counter",
+		"The number of messages rejected by the content filter.")
+	for _, kind := range []string{filteredPost, filteredComment} {
+		fmt.Fprintf(w, "yearbook_filter_rejections_total%s %d\n",
+			formatLabels("kind", kind), m.filterRejections[kind])
+	}
+
+	writeHeader(w, "yearbook_google_userinfo_duration_seconds", "histogram",
+		"The time taken by calls to the Google userinfo API.")
+	m.userinfoDuration.write(w, "yearbook_google_userinfo_duration_seconds", nil)
+}
+
+// writeHeader writes the help and type lines of a metric.
+func writeHeader(w io.Writer, name, kind, help string) {
+	fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s %s\n", name, help, name, kind)
+}
+
+// labelEscaper escapes label values.
+var labelEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// formatLabels formats label name and value pairs.
+func formatLabels(pairs ...string) string {
+	if len(pairs) == 0 {
+		return ""
+	}
+	var labels []string
+	for i := 0; i+1 < len(pairs); i += 2 {
+		labels = append(labels,
+			fmt.Sprintf(`%s="%s"`, pairs[i], labelEscaper.Replace(pairs[i+1])))
+	}
+	return "{" + strings.Join(labels, ",") + "}"
+}
+
+// measure is the middleware that counts and times every request by the
+// route it matched.
+func (api *API) measure() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		start := time.Now()
+		ctx.Next()
+
+		route := ctx.FullPath()
+		if route == "" {
+			route = "unmatched"
+		}
+		api.metrics.observeRequest(
+			ctx.Request.Method, route, ctx.Writer.Status(), time.Since(start),
+		)
+	}
+}
+
+// getMetrics serves the metrics in the Prometheus text format.
+func (api *API) getMetrics(ctx *gin.Context) {
+	ctx.Header("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	ctx.Status(http.StatusOK)
+	api.metrics.write(ctx.Writer)
+	api.writePoolStats(ctx.Writer)
+}
+
+// writePoolStats writes the stats of the database connection pool in the
+// Prometheus text format.
+func (api *API) writePoolStats(w io.Writer) {
+	stats := api.database.PoolStats()
+	for _, stat := range []struct {
+		name  string
+		kind  string
+		help  string
+		value uint32
+	}{
+		{"hits_total", "counter", "The number of times a free connection was found in the pool.", stats.Hits},
+		{"misses_total", "counter", "The number of times a free connection was not found in the pool.", stats.Misses},
+		{"timeouts_total", "counter", "The number of times waiting for a connection timed out.", stats.Timeouts},
+		{"connections", "gauge", "The number of connections in the pool.", stats.TotalConns},
+		{"idle_connections", "gauge", "The number of idle connections in the pool.", stats.IdleConns},
+		{"stale_connections_total", "counter", "The number of stale connections removed from the pool.", stats.StaleConns},
+	} {
+		name := "yearbook_db_pool_" + stat.name
+		writeHeader(w, name, stat.kind, stat.help)
+		fmt.Fprintf(w, "%s %d\n", name, stat.value)
+	}
+}
+
+// authorizeMetrics is the middleware that requires the metrics token as a
+// bearer token, if one is configured.
+func (api *API) authorizeMetrics() gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		token := api.config.MetricsToken
+		if token == "" {
+			return
+		}
+
+		given := strings.TrimPrefix(ctx.GetHeader("Authorization"), "Bearer ")
+		if subtle.ConstantTimeCompare([]byte(given), []byte(token)) != 1 {
+			ctx.AbortWithStatusJSON(
+				http.StatusUnauthorized, gr("", errUnauthorized.Error()),
+			)
+		}
+	}
+}
+
+// initializeMetrics serves the metrics on their own address if one is
+// configured, or on the API server if a metrics token is configured.
+// Otherwise they are not served at all.
+func (api *API) initializeMetrics() {
+	switch {
+	case api.config.MetricsAddr != "":
+		r := gin.New()
+		r.Use(gin.Recovery())
+		r.GET(metricsPath, api.authorizeMetrics(), api.getMetrics)
+		api.metricsServer = &http.Server{
+			Addr:         api.config.MetricsAddr,
+			Handler:      r,
+			ReadTimeout:  api.config.ReadTimeout,
+			WriteTimeout: api.config.WriteTimeout,
+			IdleTimeout:  api.config.IdleTimeout,
+		}
+		api.log.Infof("metrics to be served on %s", api.config.MetricsAddr)
+	case api.config.MetricsToken != "":
+		api.router.GET(metricsPath, api.authorizeMetrics(), api.getMetrics)
+		api.log.Infof("metrics to be served on the API server")
+	default:
+		api.log.Infof("metrics are not served, since neither a metrics address nor a token is set")
+	}
+}
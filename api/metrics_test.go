@@ -0,0 +1,62 @@
+package api
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+)
+
+func TestHistogramWrite(t *testing.T) {
+	var h histogram
+	for _, v := range []float64{.001, .005, .02, .02, 3, 60} {
+		h.observe(v)
+	}
+
+	var buf bytes.Buffer
+	h.write(&buf, "test_seconds", []string{"route", "/api/getPost/:id"})
+	output := buf.String()
+
+	// Every bucket counts the observations at or below its bound
+	for _, line := range []string{
+		`test_seconds_bucket{route="/api/getPost/:id",le="0.005"} 2`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="0.01"} 2`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="0.025"} 4`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="2.5"} 4`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="5"} 5`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="10"} 5`,
+		`test_seconds_bucket{route="/api/getPost/:id",le="+Inf"} 6`,
+		`test_seconds_sum{route="/api/getPost/:id"} 63.046`,
+		`test_seconds_count{route="/api/getPost/:id"} 6`,
+	} {
+		if !strings.Contains(output, line+"\n") {
+			t.Fatalf("missing line %s in\n%s", line, output)
+		}
+	}
+
+	// An empty histogram still has every bucket
+	buf.Reset()
+	(&histogram{}).write(&buf, "empty_seconds", nil)
+	if !strings.Contains(buf.String(), `empty_seconds_bucket{le="0.005"} 0`+"\n") {
+		t.Fatalf("missing empty bucket in\n%s", buf.String())
+	}
+	if !strings.Contains(buf.String(), "empty_seconds_count 0\n") {
+		t.Fatalf("missing empty count in\n%s", buf.String())
+	}
+}
+
+func TestFormatLabels(t *testing.T) {
+	if labels := formatLabels(); labels != "" {
+		t.Fatalf("unexpected labels %s for no pairs", labels)
+	}
+
+	labels := formatLabels("kind", `a "quoted" \path`+"\nline")
+	expected := `{kind="a \"quoted\" \\path\nline"}`
+	if labels != expected {
+		t.Fatalf("labels %s, expected %s", labels, expected)
+	}
+
+	labels = formatLabels("method", "GET", "status", "200")
+	if labels != `{method="GET",status="200"}` {
+		t.Fatalf("unexpected labels %s", labels)
+	}
+}
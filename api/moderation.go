@@ -0,0 +1,102 @@
+package api
+
+import (
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// getModerationQueue gets all posts waiting to be reviewed.
+func (api *API) getModerationQueue(ctx *gin.Context) {
+	posts, err := api.database.GetModerationQueue()
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(posts))
+}
+
+// approvePost handles a request to approve a pending post.
+func (api *API) approvePost(ctx *gin.Context) {
+	postID := ctx.Param("id")
+
+	// The reason is optional when approving
+	var request moderatePostRequest
+	ctx.ShouldBindJSON(&request)
+
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+
+	// The post is about to go live, so let the sender know. The recipients
+	// are only told about it the first time it goes live, and not when a
+	// post hidden by reports is approved again.
+	notifs, err := api.moderationNotifications(post, true, request.Reason)
+	if api.check(err, ctx) {
+		return
+	}
+	wasLive := !post.LiveAt.IsZero()
+	if !wasLive {
+		newPostNotifs, err := api.newPostNotifications(post)
+		if api.check(err, ctx) {
+			return
+		}
+		notifs = append(notifs, newPostNotifs...)
+	}
+
+	moderator := models.Username(viewer(ctx))
+	err = api.database.ModeratePost(
+		postID, models.Approved, moderator, request.Reason, notifs...,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	post.Status = models.Approved
+	api.publishPost(post)
+	events := []*models.InAppNotification{moderationEvent(post, moderator, true)}
+	if !wasLive {
+		events = append(newPostEvents(post), events...)
+	}
+	api.recordEvents(events...)
+
+	api.log.Infof("%s approved post %s", moderator, postID)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// rejectPost handles a request to reject a pending post.
+func (api *API) rejectPost(ctx *gin.Context) {
+	postID := ctx.Param("id")
+
+	var request moderatePostRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+
+	// Let the sender know why
+	notifs, err := api.moderationNotifications(post, false, request.Reason)
+	if api.check(err, ctx) {
+		return
+	}
+
+	moderator := models.Username(viewer(ctx))
+	err = api.database.ModeratePost(
+		postID, models.Rejected, moderator, request.Reason, notifs...,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	api.recordEvents(moderationEvent(post, moderator, false))
+
+	api.log.Infof("%s rejected post %s: %s", moderator, postID, request.Reason)
+	ctx.JSON(http.StatusOK, ok())
+}
@@ -0,0 +1,200 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/crypto"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// siteURL returns the URL of the frontend.
+func (api *API) siteURL() string {
+	return fmt.Sprintf("%s://%s", api.config.Protocol, api.config.Provider)
+}
+
+// postURL returns the URL of a post on the frontend.
+func (api *API) postURL(postID string) string {
+	return fmt.Sprintf("%s/post/%s", api.siteURL(), postID)
+}
+
+// userEvents are the events that users can set email preferences for.
+var userEvents = []string{
+	mail.NewPostTemplate,
+	mail.ModerationTemplate,
+	mail.RecipientActionTemplate,
+	mail.ReactionTemplate,
+	mail.ReciprocateTemplate,
+}
+
+// unsubscribeURL returns the one-click unsubscribe URL for a user and an
+// event. The event "all" turns off every email. It fails if there is no
+// unsubscribe secret to sign the link with.
+func (api *API) unsubscribeURL(
+	username models.Username,
+	event string,
+) (string, error) {
+	token, err := crypto.NewSignedToken(
+		[]byte(api.config.Mail.UnsubscribeSecret),
+		string(username)+"|"+event,
+	)
+	if err != nil {
+		return "", err
+	}
+	return fmt.Sprintf("%s%s/unsubscribe?token=%s",
+		api.siteURL(), defaultAPIRoot, url.QueryEscape(token)), nil
+}
+
+// notifyUsers renders an email template for each of the given users who
+// wants to be emailed about the event right away. The data of each email
+// is made by the data func given the user's footer.
+func (api *API) notifyUsers(
+	template string,
+	users []models.Username,
+	data func(footer mail.Footer) interface{},
+) ([]*models.Notification, error) {
+	if !api.config.Mail.Enabled {
+		return nil, nil
+	}
+
+	var notifs []*models.Notification
+	for _, user := range users {
+		prefs, err := api.database.GetPreferences(string(user))
+		if err != nil {
+			return nil, err
+		}
+		if prefs.CadenceFor(template) != models.Immediate {
+			continue
+		}
+
+		unsubscribe, err := api.unsubscribeURL(user, template)
+		if err != nil {
+			return nil, err
+		}
+		msg, err := mail.Render(template, data(mail.Footer{
+			UnsubscribeURL: unsubscribe,
+		}))
+		if err != nil {
+			return nil, err
+		}
+
+		notif := models.NewNotification(
+			template, []string{user.Email()}, msg.Subject, msg.Text, msg.HTML,
+		)
+		notif.Unsubscribe = unsubscribe
+		notifs = append(notifs, notif)
+	}
+	return notifs, nil
+}
+
+// newPostNotifications constructs the emails telling the recipients of a
+// post that they have been congratulated.
+func (api *API) newPostNotifications(post models.Post) ([]*models.Notification, error) {
+	return api.notifyUsers(mail.NewPostTemplate, post.Recipients,
+		func(footer mail.Footer) interface{} {
+			return mail.NewPostData{
+				Footer:  footer,
+				Sender:  post.Sender.Name(),
+				Message: post.Message,
+				PostURL: api.postURL(post.PostID),
+			}
+		},
+	)
+}
+
+// moderationNotifications constructs the email telling the sender of a
+// post whether it was approved.
+func (api *API) moderationNotifications(
+	post models.Post,
+	approved bool,
+	reason string,
+) ([]*models.Notification, error) {
+	return api.notifyUsers(mail.ModerationTemplate,
+		[]models.Username{post.Sender},
+		func(footer mail.Footer) interface{} {
+			return mail.ModerationData{
+				Footer:   footer,
+				Approved: approved,
+				Reason:   reason,
+				Message:  post.Message,
+				PostURL:  api.postURL(post.PostID),
+			}
+		},
+	)
+}
+
+// recipientActionNotifications constructs the email telling the sender of
+// a post that a recipient hid the post or removed themselves from it.
+func (api *API) recipientActionNotifications(
+	post models.Post,
+	recipient models.Username,
+	action string,
+) ([]*models.Notification, error) {
+	return api.notifyUsers(mail.RecipientActionTemplate,
+		[]models.Username{post.Sender},
+		func(footer mail.Footer) interface{} {
+			return mail.RecipientActionData{
+				Footer:    footer,
+				Recipient: recipient.Name(),
+				Action:    action,
+				Message:   post.Message,
+				PostURL:   api.postURL(post.PostID),
+			}
+		},
+	)
+}
+
+// modReviewNotifications constructs the email telling the moderators that
+// a post is waiting to be reviewed.
+func (api *API) modReviewNotifications(post models.Post) ([]*models.Notification, error) {
+	var recipients []string
+	for _, recip := range post.Recipients {
+		recipients = append(recipients, string(recip))
+	}
+
+	msg, err := mail.Render(mail.ModReviewTemplate, mail.ModReviewData{
+		Sender:     string(post.Sender),
+		Recipients: recipients,
+		Message:    post.Message,
+		PostID:     post.PostID,
+		PostURL:    api.postURL(post.PostID),
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return []*models.Notification{models.NewNotification(
+		mail.ModReviewTemplate,
+		[]string{api.config.Mail.ModEmail},
+		msg.Subject, msg.Text, msg.HTML,
+	)}, nil
+}
+
+// previewEmail renders an email template with sample data. The HTML is
+// returned as a page if the format is html, otherwise the whole rendered
+// message is returned.
+func (api *API) previewEmail(ctx *gin.Context) {
+	template := ctx.Param("template")
+
+	msg, err := mail.Render(template, mail.SampleData(template))
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+
+	switch ctx.Query("format") {
+	case "html":
+		ctx.Data(http.StatusOK, "text/html; charset=utf-8", []byte(msg.HTML))
+	case "text":
+		ctx.String(http.StatusOK, msg.Text)
+	default:
+		ctx.JSON(http.StatusOK, gr(msg))
+	}
+}
+
+// getEmailTemplates gets the names of the email templates.
+func (api *API) getEmailTemplates(ctx *gin.Context) {
+	ctx.JSON(http.StatusOK, gr(mail.TemplateNames()))
+}
@@ -8,10 +8,10 @@ import (
 	"net/http"
 	"path"
 	"strings"
+	"time"
 
 	"github.com/gin-contrib/sessions"
 	"github.com/gin-gonic/gin"
-	"github.com/mattnappo/yearbook/common"
 	"github.com/mattnappo/yearbook/crypto"
 	"github.com/mattnappo/yearbook/models"
 	"golang.org/x/oauth2"
@@ -39,8 +39,8 @@ func (api *API) initializeOAuth() {
 	// Configure the OAuth2 client
 	api.oauthConfig = &oauth2.Config{
 		RedirectURL:  api.callbackURL,
-		ClientID:     common.GetEnv("GOOGLE_CLIENT_ID"),
-		ClientSecret: common.GetEnv("GOOGLE_CLIENT_SECRET"),
+		ClientID:     api.config.GoogleClientID,
+		ClientSecret: api.config.GoogleClientSecret,
 		Scopes: []string{
 			"https://www.googleapis.com/auth/userinfo.email",
 		},
@@ -69,7 +69,9 @@ func (api *API) getUserInfo(token *oauth2.Token) (user, error) {
 	client := api.oauthConfig.Client(oauth2.NoContext, token)
 
 	// Query the Google API to get information about the user
+	start := time.Now()
 	userinfoReq, err := client.Get("https://www.googleapis.com/oauth2/v3/userinfo")
+	api.metrics.observeUserinfo(time.Since(start))
 	if err != nil {
 		return user{}, err
 	}
@@ -107,12 +109,41 @@ func extractBearerToken(ctx *gin.Context) (string, error) {
 	return strings.ReplaceAll(authHeader[1], " ", ""), nil
 }
 
+// extractStreamToken extracts the token of a request to open an event
+// stream. Browsers cannot set headers on an EventSource, so the token
+// cookie set when authorizing is accepted too.
+func extractStreamToken(ctx *gin.Context) (string, error) {
+	token, err := extractBearerToken(ctx)
+	if err == nil {
+		return token, nil
+	}
+	cookie, err := ctx.Request.Cookie("token")
+	if err != nil || cookie.Value == "" {
+		return "", errors.New("no authorization header or token cookie")
+	}
+	return cookie.Value, nil
+}
+
 // authorizeRequest is the middleware used to authorize a request for a
 // certain endpoint group.
 func (api *API) authorizeRequest() gin.HandlerFunc {
+	return api.authorizeToken(extractBearerToken)
+}
+
+// authorizeStream is the middleware used to authorize a request to open
+// an event stream.
+func (api *API) authorizeStream() gin.HandlerFunc {
+	return api.authorizeToken(extractStreamToken)
+}
+
+// authorizeToken authorizes a request by the token that extract finds in
+// it.
+func (api *API) authorizeToken(
+	extract func(*gin.Context) (string, error),
+) gin.HandlerFunc {
 	return func(ctx *gin.Context) {
 		// Extract the bearer token
-		headerTokenString, err := extractBearerToken(ctx)
+		headerTokenString, err := extract(ctx)
 		if api.check(err, ctx, http.StatusUnauthorized) {
 			return
 		}
@@ -143,6 +174,13 @@ func (api *API) authorizeRequest() gin.HandlerFunc {
 			api.check(errUnauthorized, ctx, http.StatusUnauthorized)
 			return
 		}
+
+		// Remember who made the request for the rest of the handlers
+		username, err := models.UsernameFromEmail(u.Email)
+		if api.check(err, ctx, http.StatusUnauthorized) {
+			return
+		}
+		ctx.Set("username", string(username))
 		ctx.Next()
 	}
 }
@@ -174,6 +212,41 @@ func (api *API) authenticate(ctx *gin.Context, username string) error {
 	return nil
 }
 
+// viewer returns the username of the authorized user making a request.
+func viewer(ctx *gin.Context) string {
+	return ctx.GetString("username")
+}
+
+// viewerOf gets the user making a request, for checking which posts they
+// can see.
+func (api *API) viewerOf(ctx *gin.Context) models.Viewer {
+	return models.Viewer{
+		Username:  models.Username(viewer(ctx)),
+		Moderator: api.hasRole(ctx, models.Moderator),
+	}
+}
+
+// requireRole is the middleware used to only allow users with at least
+// the given role to access a route.
+func (api *API) requireRole(role models.Role) gin.HandlerFunc {
+	return func(ctx *gin.Context) {
+		if !api.hasRole(ctx, role) {
+			api.check(errUnauthorized, ctx, http.StatusForbidden)
+			return
+		}
+		ctx.Next()
+	}
+}
+
+// hasRole checks if the user making a request has at least the given role.
+func (api *API) hasRole(ctx *gin.Context, role models.Role) bool {
+	userRole, err := api.database.GetUserRole(viewer(ctx))
+	if err != nil {
+		return false
+	}
+	return userRole >= role
+}
+
 // login handles a request to login.
 func (api *API) login(ctx *gin.Context) {
 	api.log.Infof("request to login")
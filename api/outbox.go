@@ -0,0 +1,225 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/juju/loggo"
+	"github.com/mattnappo/yearbook/database"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// outboxWorkers is the number of workers sending notifications.
+	outboxWorkers = 4
+
+	// outboxPollInterval is how often the outbox is checked for
+	// notifications that are due.
+	outboxPollInterval = 5 * time.Second
+
+	// outboxBatchSize is the most notifications claimed in one poll.
+	outboxBatchSize = 50
+
+	// maxSendAttempts is the number of attempts before a notification is
+	// dead-lettered.
+	maxSendAttempts = 8
+
+	// baseBackoff and maxBackoff bound the time between attempts.
+	baseBackoff = 30 * time.Second
+	maxBackoff  = 6 * time.Hour
+)
+
+// outbox delivers the notifications in the database outbox through a
+// mailer, using a pool of workers.
+type outbox struct {
+	database *database.Database
+	mailer   mail.Mailer
+	metrics  *metrics
+	log      *loggo.Logger
+
+	jobs chan models.Notification
+	quit chan struct{}
+	wg   sync.WaitGroup
+}
+
+// newOutbox constructs a new *outbox.
+func newOutbox(
+	db *database.Database,
+	mailer mail.Mailer,
+	metrics *metrics,
+	log *loggo.Logger,
+) *outbox {
+	return &outbox{
+		database: db,
+		mailer:   mailer,
+		metrics:  metrics,
+		log:      log,
+		jobs:     make(chan models.Notification),
+		quit:     make(chan struct{}),
+	}
+}
+
+// start starts the dispatcher and the workers.
+func (o *outbox) start() error {
+	// Anything that was being sent when the server stopped goes back in
+	err := o.database.ResetSendingNotifications()
+	if err != nil {
+		return err
+	}
+
+	o.wg.Add(1)
+	go o.dispatch()
+	for i := 0; i < outboxWorkers; i++ {
+		o.wg.Add(1)
+		go o.work()
+	}
+
+	o.log.Infof("started notification outbox with %d workers", outboxWorkers)
+	return nil
+}
+
+// stop stops the dispatcher and waits for the workers to finish the
+// notifications they are sending.
+func (o *outbox) stop() {
+	close(o.quit)
+	o.wg.Wait()
+	o.log.Infof("stopped notification outbox")
+}
+
+// dispatch polls the outbox and hands the due notifications to the
+// workers.
+func (o *outbox) dispatch() {
+	defer o.wg.Done()
+	defer close(o.jobs)
+
+	ticker := time.NewTicker(outboxPollInterval)
+	defer ticker.Stop()
+
+	for {
+		notifs, err := o.database.ClaimNotifications(outboxBatchSize)
+		if err != nil {
+			o.log.Errorf("could not claim notifications: %s", err)
+		}
+
+		for _, notif := range notifs {
+			select {
+			case o.jobs <- notif:
+			case <-o.quit:
+				// Put the rest back for the next start
+				o.database.ResetSendingNotifications()
+				return
+			}
+		}
+
+		select {
+		case <-ticker.C:
+		case <-o.quit:
+			return
+		}
+	}
+}
+
+// work sends the notifications handed to it by the dispatcher.
+func (o *outbox) work() {
+	defer o.wg.Done()
+	for notif := range o.jobs {
+		o.send(notif)
+	}
+}
+
+// send attempts to deliver a single notification and records the result.
+func (o *outbox) send(notif models.Notification) {
+	msg := mail.Message{
+		To:      notif.To,
+		Subject: notif.Subject,
+		Text:    notif.Text,
+		HTML:    notif.HTML,
+	}
+	if notif.Unsubscribe != "" {
+		msg.Headers = map[string]string{
+			"List-Unsubscribe":      "<" + notif.Unsubscribe + ">",
+			"List-Unsubscribe-Post": "List-Unsubscribe=One-Click",
+		}
+	}
+
+	err := o.mailer.Send(msg)
+	o.metrics.notificationSent(err)
+	if err == nil {
+		err = o.database.MarkNotificationSent(notif.ID)
+		if err != nil {
+			o.log.Errorf("could not mark notification %d sent: %s", notif.ID, err)
+		}
+		return
+	}
+
+	attempts := notif.Attempts + 1
+	dead := attempts >= maxSendAttempts
+	if dead {
+		o.log.Errorf("notification %d is dead after %d attempts: %s",
+			notif.ID, attempts, err)
+	} else {
+		o.log.Warningf("notification %d failed (attempt %d): %s",
+			notif.ID, attempts, err)
+	}
+
+	err = o.database.MarkNotificationFailed(
+		notif.ID, err, time.Now().Add(backoff(attempts)), dead,
+	)
+	if err != nil {
+		o.log.Errorf("could not mark notification %d failed: %s", notif.ID, err)
+	}
+}
+
+// backoff returns the time to wait before the next attempt, given the
+// number of attempts so far.
+func backoff(attempts int) time.Duration {
+	wait := baseBackoff
+	for i := 1; i < attempts; i++ {
+		wait *= 2
+		if wait >= maxBackoff {
+			return maxBackoff
+		}
+	}
+	return wait
+}
+
+// enqueue adds newly rendered notifications to the outbox outside of any
+// other database change, logging if it fails.
+func (api *API) enqueue(notifs []*models.Notification, err error) {
+	if err == nil && len(notifs) > 0 {
+		err = api.database.EnqueueNotification(notifs...)
+	}
+	if err != nil {
+		api.log.Errorf("could not enqueue notifications: %s", err)
+	}
+}
+
+// getFailedEmails gets the notifications that failed to send.
+func (api *API) getFailedEmails(ctx *gin.Context) {
+	notifs, err := api.database.GetFailedNotifications()
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(notifs))
+}
+
+// retryEmail handles a request to retry sending a failed notification.
+func (api *API) retryEmail(ctx *gin.Context) {
+	id, err := strconv.ParseInt(ctx.Param("id"), 10, 64)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	err = api.database.RetryNotification(id)
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+
+	api.log.Infof("%s retried notification %d", viewer(ctx), id)
+	ctx.JSON(http.StatusOK, ok())
+}
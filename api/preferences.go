@@ -0,0 +1,167 @@
+package api
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html/template"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/crypto"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errInvalidUnsubscribe is thrown when an unsubscribe link is invalid.
+var errInvalidUnsubscribe = errors.New("invalid unsubscribe link")
+
+// unsubscribePage asks the user to confirm that they want to unsubscribe.
+var unsubscribePage = template.Must(template.New("unsubscribe").Parse(`<!DOCTYPE html>
+<html>
+<head><meta charset="utf-8"><title>Unsubscribe</title></head>
+<body>
+<form method="post" action="{{.Action}}">
+<p>Stop sending {{if eq .Event "all"}}any emails{{else}}{{.Event}} emails{{end}} to {{.Username}}?</p>
+<button type="submit">Unsubscribe</button>
+</form>
+</body>
+</html>
+`))
+
+// getNotificationPreferences gets the requesting user's notification
+// preferences.
+func (api *API) getNotificationPreferences(ctx *gin.Context) {
+	prefs, err := api.database.GetPreferences(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(prefs))
+}
+
+// updateNotificationPreferences handles a request to update the requesting
+// user's notification preferences.
+func (api *API) updateNotificationPreferences(ctx *gin.Context) {
+	var request updatePreferencesRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	prefs, err := api.database.GetPreferences(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	// Only change what was sent
+	if request.EmailEnabled != nil {
+		prefs.EmailEnabled = *request.EmailEnabled
+	}
+	for event, cadence := range request.Cadences {
+		err = validateCadence(event, cadence)
+		if api.check(err, ctx, http.StatusBadRequest) {
+			return
+		}
+		prefs.Cadences[event] = cadence
+	}
+
+	err = api.database.SetPreferences(prefs)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s updated notification preferences", viewer(ctx))
+	ctx.JSON(http.StatusOK, gr(prefs))
+}
+
+// verifyUnsubscribe verifies a signed unsubscribe token and returns the
+// user and the event it unsubscribes from.
+func (api *API) verifyUnsubscribe(token string) (string, string, error) {
+	payload, err := crypto.VerifySignedToken(
+		[]byte(api.config.Mail.UnsubscribeSecret), token,
+	)
+	parts := strings.Split(payload, "|")
+	if err != nil || len(parts) != 2 {
+		return "", "", errInvalidUnsubscribe
+	}
+	return parts[0], parts[1], nil
+}
+
+// confirmUnsubscribe shows the page that confirms an unsubscribe link from
+// an email. Following the link does not change anything, so that link
+// scanners and mail prefetchers can not unsubscribe anyone.
+func (api *API) confirmUnsubscribe(ctx *gin.Context) {
+	token := ctx.Query("token")
+	username, event, err := api.verifyUnsubscribe(token)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	var page bytes.Buffer
+	err = unsubscribePage.Execute(&page, map[string]string{
+		"Action": path.Join(api.root, "unsubscribe") +
+			"?token=" + url.QueryEscape(token),
+		"Event":    event,
+		"Username": username,
+	})
+	if api.check(err, ctx) {
+		return
+	}
+	ctx.Data(http.StatusOK, "text/html; charset=utf-8", page.Bytes())
+}
+
+// unsubscribe handles the confirmation of an unsubscribe link, or a
+// one-click unsubscribe POST from a mail client (RFC 8058). It does not
+// need a bearer token because the link itself is signed.
+func (api *API) unsubscribe(ctx *gin.Context) {
+	username, event, err := api.verifyUnsubscribe(ctx.Query("token"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	prefs, err := api.database.GetPreferences(username)
+	if api.check(err, ctx) {
+		return
+	}
+	if event == "all" {
+		prefs.EmailEnabled = false
+	} else {
+		prefs.Cadences[event] = models.Never
+	}
+
+	err = api.database.SetPreferences(prefs)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s unsubscribed from %s", username, event)
+	ctx.String(http.StatusOK, "You have been unsubscribed.")
+}
+
+// validateCadence checks that a cadence can be used for an event. Only new
+// posts can be sent in a daily digest.
+func validateCadence(event string, cadence models.Cadence) error {
+	known := false
+	for _, userEvent := range userEvents {
+		known = known || userEvent == event
+	}
+	if !known {
+		return fmt.Errorf("unknown event '%s'", event)
+	}
+
+	// The reciprocity reminder is always weekly, so it is just turned on
+	// (immediate) or off (never)
+	switch cadence {
+	case models.Immediate, models.Never:
+		return nil
+	case models.Daily:
+		if event == mail.NewPostTemplate {
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid cadence '%s' for event '%s'", cadence, event)
+}
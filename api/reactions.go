@@ -0,0 +1,101 @@
+package api
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errInvalidEmoji is thrown when reacting with an emoji that is not
+// allowed.
+var errInvalidEmoji = errors.New("emoji is not an allowed reaction")
+
+// addReaction handles a request to react to a post with an emoji.
+func (api *API) addReaction(ctx *gin.Context) {
+	postID, emoji := ctx.Param("id"), ctx.Param("emoji")
+	username := models.Username(viewer(ctx))
+
+	if !api.validEmoji(emoji) {
+		api.check(errInvalidEmoji, ctx, http.StatusBadRequest)
+		return
+	}
+
+	// Only live posts can be reacted to
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if err != nil || post.Status != models.Approved {
+		api.check(errPostNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	first, err := api.database.AddReaction(&models.Reaction{
+		PostID:    postID,
+		Username:  username,
+		Emoji:     emoji,
+		Timestamp: time.Now(),
+	})
+	if api.check(err, ctx, http.StatusConflict) {
+		return
+	}
+
+	// Let the sender know about the first reaction to their post
+	if first {
+		api.enqueue(api.reactionNotifications(post, username, emoji))
+		api.recordEvents(models.NewInAppNotification(
+			post.Sender, mail.ReactionTemplate, username, postID,
+			fmt.Sprintf("%s reacted %s to your post.", username.Name(), emoji),
+		))
+	}
+
+	api.log.Infof("%s reacted %s to post %s", username, emoji, postID)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// removeReaction handles a request to remove a reaction from a post.
+func (api *API) removeReaction(ctx *gin.Context) {
+	postID, emoji := ctx.Param("id"), ctx.Param("emoji")
+	username := models.Username(viewer(ctx))
+
+	err := api.database.RemoveReaction(postID, username, emoji)
+	if api.check(err, ctx) {
+		return
+	}
+
+	api.log.Infof("%s removed reaction %s from post %s", username, emoji, postID)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// reactionNotifications constructs the email telling the sender of a post
+// that someone reacted to it.
+func (api *API) reactionNotifications(
+	post models.Post,
+	reactor models.Username,
+	emoji string,
+) ([]*models.Notification, error) {
+	return api.notifyUsers(mail.ReactionTemplate,
+		[]models.Username{post.Sender},
+		func(footer mail.Footer) interface{} {
+			return mail.ReactionData{
+				Footer:  footer,
+				Reactor: reactor.Name(),
+				Emoji:   emoji,
+				Message: post.Message,
+				PostURL: api.postURL(post.PostID),
+			}
+		},
+	)
+}
+
+// validEmoji checks if an emoji is one of the allowed reactions.
+func (api *API) validEmoji(emoji string) bool {
+	for _, allowed := range api.config.ReactionEmojis {
+		if emoji == allowed {
+			return true
+		}
+	}
+	return false
+}
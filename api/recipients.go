@@ -0,0 +1,78 @@
+package api
+
+import (
+	"errors"
+	"net/http"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errNotRecipient is thrown when a user acts on a post as a recipient but
+// is not one of its recipients.
+var errNotRecipient = errors.New("not a recipient of this post")
+
+// hidePost handles a request by a recipient to hide a post from their
+// profile and activity.
+func (api *API) hidePost(ctx *gin.Context) {
+	api.recipientAction(ctx, true)
+}
+
+// removeSelfFromPost handles a request by a recipient to remove themselves
+// from the recipients of a post.
+func (api *API) removeSelfFromPost(ctx *gin.Context) {
+	api.recipientAction(ctx, false)
+}
+
+// recipientAction hides a post from a recipient or removes the recipient
+// from it, and lets the sender know.
+func (api *API) recipientAction(ctx *gin.Context, hide bool) {
+	postID := ctx.Param("id")
+	username := models.Username(viewer(ctx))
+
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+	if !post.HasRecipient(username) {
+		api.check(errNotRecipient, ctx, http.StatusForbidden)
+		return
+	}
+
+	action := "removed themselves from"
+	changed := true
+	if hide {
+		action = "hid"
+		changed, err = api.database.HidePost(username, postID)
+	} else {
+		err = api.database.RemoveRecipient(username, postID)
+	}
+	if api.check(err, ctx) {
+		return
+	}
+
+	// The sender was already told the first time
+	if !changed {
+		ctx.JSON(http.StatusOK, ok())
+		return
+	}
+
+	// Let the sender know
+	api.enqueue(api.recipientActionNotifications(post, username, action))
+	api.recordEvents(recipientActionEvent(post, username, action))
+
+	api.log.Infof("%s %s post %s", username, action, postID)
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// getHiddenPosts gets the posts that a user has hidden.
+func (api *API) getHiddenPosts(ctx *gin.Context) {
+	username := ctx.Param("username")
+
+	posts, err := api.database.GetUserHidden(username)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(posts))
+}
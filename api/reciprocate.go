@@ -0,0 +1,120 @@
+package api
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/mail"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// reminderInterval is the time between two reciprocity reminders to
+	// the same user.
+	reminderInterval = 7 * 24 * time.Hour
+
+	// reminderCheckInterval is how often the reminder job looks for users
+	// who are due for a reminder.
+	reminderCheckInterval = time.Hour
+)
+
+// composeURL returns the URL of the frontend page that starts a new post
+// to a user.
+func (api *API) composeURL(recipient models.Username) string {
+	return fmt.Sprintf("%s/create?to=%s",
+		api.siteURL(), url.QueryEscape(string(recipient)))
+}
+
+// getReciprocations gets the people who congratulated the requesting user
+// that the user has not congratulated back, most recent first.
+func (api *API) getReciprocations(ctx *gin.Context) {
+	reciprocations, err := api.reciprocations(viewer(ctx))
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(reciprocations))
+}
+
+// reciprocations gets the people a user has not congratulated back, with
+// the links that start a post to each of them.
+func (api *API) reciprocations(username string) ([]models.Reciprocation, error) {
+	reciprocations, err := api.database.GetReciprocations(username)
+	if err != nil {
+		return nil, err
+	}
+	for i := range reciprocations {
+		reciprocations[i].ComposeURL = api.composeURL(reciprocations[i].Username)
+	}
+	return reciprocations, nil
+}
+
+// sendReminders sends the weekly reciprocity reminder to every user who
+// has someone to congratulate back and is due for one.
+func (api *API) sendReminders() error {
+	usernames, err := api.database.GetReciprocationUsers()
+	if err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, username := range usernames {
+		err = api.sendReminder(username, now)
+		if err != nil {
+			api.log.Errorf("could not send reminder to %s: %s", username, err)
+		}
+	}
+	return nil
+}
+
+// sendReminder sends a user one email listing the people they have not
+// congratulated back, unless they turned the reminder off or already had
+// one this week.
+func (api *API) sendReminder(username models.Username, now time.Time) error {
+	prefs, err := api.database.GetPreferences(string(username))
+	if err != nil {
+		return err
+	}
+	if prefs.CadenceFor(mail.ReciprocateTemplate) != models.Immediate ||
+		now.Sub(prefs.LastReminder) < reminderInterval {
+		return nil
+	}
+
+	reciprocations, err := api.reciprocations(string(username))
+	if err != nil || len(reciprocations) == 0 {
+		return err
+	}
+
+	var people []mail.ReciprocatePerson
+	for _, reciprocation := range reciprocations {
+		people = append(people, mail.ReciprocatePerson{
+			Name:       reciprocation.Username.Name(),
+			ComposeURL: reciprocation.ComposeURL,
+		})
+	}
+
+	unsubscribe, err := api.unsubscribeURL(username, mail.ReciprocateTemplate)
+	if err != nil {
+		return err
+	}
+	msg, err := mail.Render(mail.ReciprocateTemplate, mail.ReciprocateData{
+		Footer:  mail.Footer{UnsubscribeURL: unsubscribe},
+		Name:    username.Name(),
+		People:  people,
+		SiteURL: api.siteURL(),
+	})
+	if err != nil {
+		return err
+	}
+	notif := models.NewNotification(
+		mail.ReciprocateTemplate, []string{username.Email()},
+		msg.Subject, msg.Text, msg.HTML,
+	)
+	notif.Unsubscribe = unsubscribe
+
+	api.log.Infof("sending reminder about %d people to %s", len(people), username)
+	return api.database.RecordReminder(username, now, notif)
+}
@@ -0,0 +1,113 @@
+package api
+
+import (
+	"net/http"
+	"strconv"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// reportPost handles a request to report a post.
+func (api *API) reportPost(ctx *gin.Context) {
+	postID := ctx.Param("id")
+
+	var request reportPostRequest
+	err := ctx.ShouldBindJSON(&request)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	// Only live posts can be reported
+	post, err := api.database.GetPost(postID, api.viewerOf(ctx))
+	if err != nil || post.Status != models.Approved {
+		api.check(errPostNotFound, ctx, http.StatusNotFound)
+		return
+	}
+
+	report, err := models.NewReport(
+		postID, viewer(ctx), request.Category, request.Reason, api.config.Limits,
+	)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	hidden, err := api.database.AddReport(report, api.config.ReportThreshold)
+	if api.check(err, ctx, http.StatusConflict) {
+		return
+	}
+
+	api.log.Infof("%s reported post %s (%s)", report.Reporter, postID, report.Category)
+
+	// Let the moderators know that the post needs another look
+	if hidden {
+		api.log.Infof("hid post %s after %d reports", postID, api.config.ReportThreshold)
+		post.Status = models.Pending
+		api.enqueue(api.modReviewNotifications(post))
+	}
+
+	ctx.JSON(http.StatusOK, ok())
+}
+
+// getReports gets all reports with a given status (open by default).
+func (api *API) getReports(ctx *gin.Context) {
+	status := models.ReportStatus(models.Open)
+	switch ctx.Query("status") {
+	case "resolved":
+		status = models.Resolved
+	case "dismissed":
+		status = models.Dismissed
+	}
+
+	reports, err := api.database.GetReports(status)
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(reports))
+}
+
+// resolveReport handles a request to resolve a report, optionally
+// removing the reported post.
+func (api *API) resolveReport(ctx *gin.Context) {
+	api.reviewReport(ctx, models.Resolved)
+}
+
+// dismissReport handles a request to dismiss a report.
+func (api *API) dismissReport(ctx *gin.Context) {
+	api.reviewReport(ctx, models.Dismissed)
+}
+
+// reviewReport reviews a report given the status to give it.
+func (api *API) reviewReport(ctx *gin.Context, status models.ReportStatus) {
+	id, err := strconv.Atoi(ctx.Param("id"))
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	var request reviewReportRequest
+	ctx.ShouldBindJSON(&request) // The note is optional
+
+	report, err := api.database.GetReport(id)
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+
+	moderator := models.Username(viewer(ctx))
+	err = api.database.ReviewReport(id, status, moderator, request.Note)
+	if api.check(err, ctx, http.StatusBadRequest) {
+		return
+	}
+
+	// Take the post down if the moderator decided to
+	if status == models.Resolved && request.Remove {
+		err = api.database.RemovePost(report.PostID, moderator, request.Note)
+		if api.check(err, ctx) {
+			return
+		}
+		api.log.Infof("%s removed post %s", moderator, report.PostID)
+	}
+
+	api.log.Infof("%s reviewed report %d", moderator, id)
+	ctx.JSON(http.StatusOK, ok())
+}
@@ -0,0 +1,91 @@
+package api
+
+import (
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// statsCacheTTL is how long the class statistics are cached for.
+	statsCacheTTL = 5 * time.Minute
+
+	// leaderboardSize is the number of users on each leaderboard.
+	leaderboardSize = 10
+)
+
+// statsCache holds the last computed class statistics.
+type statsCache struct {
+	mux   sync.Mutex
+	stats *models.Stats
+}
+
+// get returns the cached statistics, computing them again with compute if
+// they are missing or expired.
+func (c *statsCache) get(
+	compute func() (*models.Stats, error),
+) (*models.Stats, error) {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+
+	if c.stats != nil && time.Since(c.stats.ComputedAt) < statsCacheTTL {
+		return c.stats, nil
+	}
+
+	stats, err := compute()
+	if err != nil {
+		return nil, err
+	}
+	c.stats = stats
+	return stats, nil
+}
+
+// invalidate drops the cached statistics.
+func (c *statsCache) invalidate() {
+	c.mux.Lock()
+	defer c.mux.Unlock()
+	c.stats = nil
+}
+
+// getStats gets the statistics and leaderboards of the whole class.
+func (api *API) getStats(ctx *gin.Context) {
+	stats, err := api.stats.get(func() (*models.Stats, error) {
+		return api.database.GetStats(leaderboardSize)
+	})
+	if api.check(err, ctx) {
+		return
+	}
+
+	ctx.JSON(http.StatusOK, gr(stats))
+}
+
+// hideFromLeaderboard handles a request to hide a user from the
+// leaderboards.
+func (api *API) hideFromLeaderboard(ctx *gin.Context) {
+	api.setHiddenFromLeaderboard(ctx, true)
+}
+
+// showOnLeaderboard handles a request to show a hidden user on the
+// leaderboards again.
+func (api *API) showOnLeaderboard(ctx *gin.Context) {
+	api.setHiddenFromLeaderboard(ctx, false)
+}
+
+// setHiddenFromLeaderboard hides or shows the user in the request on the
+// leaderboards, and drops the cached leaderboards.
+func (api *API) setHiddenFromLeaderboard(ctx *gin.Context, hidden bool) {
+	username := ctx.Param("username")
+
+	err := api.database.SetHiddenFromLeaderboard(username, hidden)
+	if api.check(err, ctx, http.StatusNotFound) {
+		return
+	}
+	api.stats.invalidate()
+
+	api.log.Infof("%s set %s hidden from leaderboard to %t",
+		viewer(ctx), username, hidden)
+	ctx.JSON(http.StatusOK, ok())
+}
@@ -0,0 +1,238 @@
+package api
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+
+	"github.com/gin-gonic/gin"
+	"github.com/mattnappo/yearbook/models"
+)
+
+const (
+	// maxStreamsPerUser is the most event streams one user can have open.
+	maxStreamsPerUser = 5
+
+	// streamBuffer is the number of events buffered for each stream. A
+	// stream that falls further behind misses events, and can catch up by
+	// reconnecting with its Last-Event-ID.
+	streamBuffer = 32
+
+	// streamHistory is the number of recent events kept for resuming.
+	streamHistory = 1000
+
+	// heartbeatInterval is how often an idle stream is sent a heartbeat.
+	heartbeatInterval = 15 * time.Second
+)
+
+// The names of the events sent to streams.
+const (
+	postEvent         = "post"
+	postDeletedEvent  = "post_deleted"
+	inboundPostEvent  = "inbound_post"
+	notificationEvent = "notification"
+
+	// resetEvent tells a client that the events it missed cannot be
+	// replayed, so it should reload what it shows.
+	resetEvent = "reset"
+)
+
+// errTooManyStreams is thrown when a user opens too many event streams.
+var errTooManyStreams = errors.New("too many open event streams")
+
+// event is a server-sent event.
+type event struct {
+	ID   int64
+	Name string
+	Data interface{}
+	To   string // The user it is for, or empty for everyone
+}
+
+// subscriber is a single open event stream.
+type subscriber struct {
+	username string
+	events   chan event
+}
+
+// hub is an in-process pub/sub hub that fans events out to the open event
+// streams.
+type hub struct {
+	mux         sync.Mutex
+	nextID      int64
+	history     []event
+	subscribers map[string]map[*subscriber]bool // By username
+	done        chan struct{}
+}
+
+// newHub constructs a new *hub. Event IDs start at the time the hub was
+// constructed, in microseconds, so they keep increasing across restarts.
+func newHub() *hub {
+	return &hub{
+		nextID:      time.Now().UnixNano() / int64(time.Microsecond),
+		subscribers: make(map[string]map[*subscriber]bool),
+		done:        make(chan struct{}),
+	}
+}
+
+// publish sends an event to a user's streams, or to every stream if to is
+// empty.
+func (h *hub) publish(name, to string, data interface{}) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	e := event{ID: h.nextID, Name: name, Data: data, To: to}
+	h.nextID++
+
+	h.history = append(h.history, e)
+	if len(h.history) > streamHistory {
+		h.history = h.history[len(h.history)-streamHistory:]
+	}
+
+	for username, subs := range h.subscribers {
+		if to != "" && to != username {
+			continue
+		}
+		for sub := range subs {
+			select {
+			case sub.events <- e:
+			default: // Too far behind
+			}
+		}
+	}
+}
+
+// subscribe opens a stream for a user, returning the events it missed
+// since lastEventID. If they can no longer be replayed, since lastEventID
+// is older than the history or is not one this hub handed out, a reset
+// event is returned instead.
+func (h *hub) subscribe(username string, lastEventID int64) (*subscriber, []event, error) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	if len(h.subscribers[username]) >= maxStreamsPerUser {
+		return nil, nil, errTooManyStreams
+	}
+
+	sub := &subscriber{username, make(chan event, streamBuffer)}
+	if h.subscribers[username] == nil {
+		h.subscribers[username] = make(map[*subscriber]bool)
+	}
+	h.subscribers[username][sub] = true
+
+	oldest := h.nextID
+	if len(h.history) > 0 {
+		oldest = h.history[0].ID
+	}
+
+	var missed []event
+	switch {
+	case lastEventID <= 0:
+	case lastEventID < oldest-1 || lastEventID >= h.nextID:
+		missed = []event{{ID: h.nextID - 1, Name: resetEvent, Data: struct{}{}}}
+	default:
+		for _, e := range h.history {
+			if e.ID > lastEventID && (e.To == "" || e.To == username) {
+				missed = append(missed, e)
+			}
+		}
+	}
+	return sub, missed, nil
+}
+
+// unsubscribe closes a stream.
+func (h *hub) unsubscribe(sub *subscriber) {
+	h.mux.Lock()
+	defer h.mux.Unlock()
+
+	delete(h.subscribers[sub.username], sub)
+	if len(h.subscribers[sub.username]) == 0 {
+		delete(h.subscribers, sub.username)
+	}
+}
+
+// close ends every open stream.
+func (h *hub) close() {
+	close(h.done)
+}
+
+// publishPost publishes a live post to its recipients, and to everyone if
+// it is public.
+func (api *API) publishPost(post models.Post) {
+	if post.Visibility == models.Public {
+		api.hub.publish(postEvent, "", post)
+	}
+	for _, recipient := range post.Recipients {
+		api.hub.publish(inboundPostEvent, string(recipient), post)
+	}
+}
+
+// publishPostDeleted publishes the deletion of a post to the users who
+// could see it: everyone if it was live and public, otherwise its sender
+// and recipients.
+func (api *API) publishPostDeleted(post models.Post) {
+	data := gin.H{"post_id": post.PostID}
+	if post.Status == models.Approved && post.Visibility == models.Public {
+		api.hub.publish(postDeletedEvent, "", data)
+		return
+	}
+	api.hub.publish(postDeletedEvent, string(post.Sender), data)
+	for _, recipient := range post.Recipients {
+		api.hub.publish(postDeletedEvent, string(recipient), data)
+	}
+}
+
+// stream handles a request to open a stream of server-sent events for
+// the requesting user.
+func (api *API) stream(ctx *gin.Context) {
+	username := viewer(ctx)
+
+	// Resume from where the client left off
+	lastEventID, _ := strconv.ParseInt(ctx.GetHeader("Last-Event-ID"), 10, 64)
+
+	sub, missed, err := api.hub.subscribe(username, lastEventID)
+	if api.check(err, ctx, http.StatusTooManyRequests) {
+		return
+	}
+	defer api.hub.unsubscribe(sub)
+
+	ctx.Header("Content-Type", "text/event-stream")
+	ctx.Header("Cache-Control", "no-cache")
+	ctx.Header("Connection", "keep-alive")
+	ctx.Header("X-Accel-Buffering", "no")
+	ctx.Status(http.StatusOK)
+
+	for _, e := range missed {
+		writeEvent(ctx, e)
+	}
+	ctx.Writer.Flush()
+
+	heartbeat := time.NewTicker(heartbeatInterval)
+	defer heartbeat.Stop()
+
+	for {
+		select {
+		case e := <-sub.events:
+			writeEvent(ctx, e)
+		case <-heartbeat.C:
+			fmt.Fprint(ctx.Writer, ": heartbeat\n\n")
+		case <-ctx.Request.Context().Done():
+			return
+		case <-api.hub.done:
+			return
+		}
+		ctx.Writer.Flush()
+	}
+}
+
+// writeEvent writes a server-sent event to a stream.
+func writeEvent(ctx *gin.Context, e event) {
+	data, err := json.Marshal(e.Data)
+	if err != nil {
+		return
+	}
+	fmt.Fprintf(ctx.Writer, "id: %d\nevent: %s\ndata: %s\n\n", e.ID, e.Name, data)
+}
@@ -0,0 +1,160 @@
+// Package cli implements the yearbook command line interface, which runs
+// the API server and administers the database.
+package cli
+
+import (
+	"bufio"
+	"errors"
+	"flag"
+	"fmt"
+	"io"
+	"strings"
+
+	"github.com/mattnappo/yearbook/common"
+	"github.com/mattnappo/yearbook/database"
+)
+
+// ErrUsage is returned when a command is used incorrectly. The usage has
+// already been printed.
+var ErrUsage = errors.New("invalid usage")
+
+// command is a subcommand of the CLI. A command either runs or has
+// subcommands of its own.
+type command struct {
+	name    string
+	args    string // The arguments, shown in the usage
+	summary string
+
+	run         func(ctx *context, args []string) error
+	subcommands []*command
+}
+
+// context is the state shared by the commands of one run of the CLI.
+type context struct {
+	in  *bufio.Reader
+	out io.Writer
+
+	path string        // The full name of the running command
+	fs   *flag.FlagSet // The parsed flags of the running command
+	db   *database.Database
+}
+
+// commands are the top-level commands of the CLI.
+var commands = []*command{
+	serveCommand,
+	schemaCommand,
+	usersCommand,
+	postsCommand,
+	tokensCommand,
+	coverageCommand,
+}
+
+// Run runs the CLI with the given arguments, not including the program
+// name.
+func Run(args []string, in io.Reader, out io.Writer) error {
+	ctx := &context{
+		in:   bufio.NewReader(in),
+		out:  out,
+		path: "yearbook",
+	}
+	defer func() {
+		if ctx.db != nil {
+			ctx.db.Disconnect()
+		}
+	}()
+
+	return dispatch(ctx, commands, args)
+}
+
+// dispatch runs the command named by the first argument.
+func dispatch(ctx *context, cmds []*command, args []string) error {
+	if len(args) == 0 {
+		printUsage(ctx, cmds)
+		return ErrUsage
+	}
+	if args[0] == "help" || args[0] == "-h" {
+		printUsage(ctx, cmds)
+		return nil
+	}
+
+	for _, cmd := range cmds {
+		if cmd.name != args[0] {
+			continue
+		}
+		ctx.path += " " + cmd.name
+		if cmd.subcommands != nil {
+			return dispatch(ctx, cmd.subcommands, args[1:])
+		}
+		return cmd.run(ctx, args[1:])
+	}
+
+	fmt.Fprintf(ctx.out, "unknown command '%s'\n\n", args[0])
+	printUsage(ctx, cmds)
+	return ErrUsage
+}
+
+// printUsage prints the commands that can be run.
+func printUsage(ctx *context, cmds []*command) {
+	fmt.Fprintf(ctx.out, "usage: %s <command>\n\ncommands:\n", ctx.path)
+	for _, cmd := range cmds {
+		usage := strings.TrimSpace(cmd.name + " " + cmd.args)
+		fmt.Fprintf(ctx.out, "  %-32s %s\n", usage, cmd.summary)
+	}
+}
+
+// flags constructs the flag set of the running command. The usage of the
+// command is printed if its arguments can not be parsed.
+func (ctx *context) flags(args string) *flag.FlagSet {
+	fs := flag.NewFlagSet(ctx.path, flag.ContinueOnError)
+	fs.String("config", common.DefaultConfigFile, "the config file to read")
+	fs.SetOutput(ctx.out)
+	fs.Usage = func() {
+		fmt.Fprintf(ctx.out, "usage: %s [flags] %s\n", ctx.path, args)
+		fs.PrintDefaults()
+	}
+	return fs
+}
+
+// parse parses the arguments of a command, which must leave exactly n
+// positional arguments.
+func (ctx *context) parse(fs *flag.FlagSet, args []string, n int) error {
+	err := fs.Parse(args)
+	if err != nil {
+		return ErrUsage
+	}
+	if fs.NArg() != n {
+		fs.Usage()
+		return ErrUsage
+	}
+	ctx.fs = fs
+	return nil
+}
+
+// config loads the config from the config file and the parsed flags.
+func (ctx *context) config() (*common.Config, error) {
+	path := ctx.fs.Lookup("config").Value.String()
+	return common.LoadConfig(path, ctx.fs)
+}
+
+// database connects to the database the first time it is needed.
+func (ctx *context) database() (*database.Database, error) {
+	if ctx.db != nil {
+		return ctx.db, nil
+	}
+
+	config, err := ctx.config()
+	if err != nil {
+		return nil, err
+	}
+	ctx.db, err = database.Connect(config.Database)
+	return ctx.db, err
+}
+
+// confirm asks the user to confirm a destructive action. Anything other
+// than "y" or "yes" is a no.
+func (ctx *context) confirm(format string, a ...interface{}) bool {
+	fmt.Fprintf(ctx.out, format+" [y/N] ", a...)
+	answer, _ := ctx.in.ReadString('\n')
+	answer = strings.ToLower(strings.TrimSpace(answer))
+	return answer == "y" || answer == "yes"
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+)
+
+var coverageCommand = &command{
+	name:    "coverage",
+	summary: "report how many posts each senior has received",
+	run:     coverageReport,
+}
+
+// coverageReport prints how many posts each senior has received and from
+// how many senders.
+func coverageReport(ctx *context, args []string) error {
+	fs := ctx.flags("")
+	format := outputFlag(fs)
+	maxPosts := fs.Int("max-posts", -1, "only report seniors with at most this many posts")
+	maxSenders := fs.Int("max-senders", -1, "only report seniors with at most this many senders")
+	err := ctx.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	coverage, err := db.GetCoverage(*maxPosts, *maxSenders)
+	if err != nil {
+		return err
+	}
+
+	t := table{headers: []string{"SENIOR", "POSTS", "SENDERS"}}
+	for _, senior := range coverage {
+		t.rows = append(t.rows, []string{
+			string(senior.Username),
+			strconv.Itoa(senior.Posts),
+			strconv.Itoa(senior.Senders),
+		})
+	}
+	err = ctx.write(*format, coverage, t)
+	if err != nil || *format != tableFormat {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "%d seniors\n", len(coverage))
+	return nil
+}
@@ -0,0 +1,50 @@
+package cli
+
+import (
+	"encoding/json"
+	"flag"
+	"fmt"
+	"strings"
+	"text/tabwriter"
+)
+
+// The output formats of the commands that print data.
+const (
+	tableFormat = "table"
+	jsonFormat  = "json"
+)
+
+// outputFlag adds the -o flag that picks the output format.
+func outputFlag(fs *flag.FlagSet) *string {
+	return fs.String("o", tableFormat, "output format (table or json)")
+}
+
+// yesFlag adds the -y flag that skips confirmation prompts.
+func yesFlag(fs *flag.FlagSet) *bool {
+	return fs.Bool("y", false, "do not ask for confirmation")
+}
+
+// table is data that can be printed as a table.
+type table struct {
+	headers []string
+	rows    [][]string
+}
+
+// write prints data in the given format, either as JSON or as the table
+// made from it.
+func (ctx *context) write(format string, data interface{}, t table) error {
+	switch format {
+	case jsonFormat:
+		encoder := json.NewEncoder(ctx.out)
+		encoder.SetIndent("", "  ")
+		return encoder.Encode(data)
+	case tableFormat:
+		w := tabwriter.NewWriter(ctx.out, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, strings.Join(t.headers, "\t"))
+		for _, row := range t.rows {
+			fmt.Fprintln(w, strings.Join(row, "\t"))
+		}
+		return w.Flush()
+	}
+	return fmt.Errorf("unknown output format '%s'", format)
+}
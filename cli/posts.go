@@ -0,0 +1,163 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+var postsCommand = &command{
+	name:    "posts",
+	summary: "manage posts",
+	subcommands: []*command{
+		{
+			name:    "list",
+			summary: "list the newest posts",
+			run:     listPosts,
+		},
+		{
+			name:    "show",
+			args:    "<post id>",
+			summary: "show a post",
+			run:     showPost,
+		},
+		{
+			name:    "delete",
+			args:    "<post id>",
+			summary: "delete a post",
+			run:     deletePost,
+		},
+	},
+}
+
+// statusNames are the names of the post statuses.
+var statusNames = map[models.Status]string{
+	models.Pending:   "pending",
+	models.Approved:  "approved",
+	models.Rejected:  "rejected",
+	models.Draft:     "draft",
+	models.Scheduled: "scheduled",
+}
+
+// postTable makes the table of a list of posts.
+func postTable(posts []models.Post) table {
+	t := table{headers: []string{
+		"POST ID", "TIME", "SENDER", "RECIPIENTS", "STATUS", "VISIBILITY", "MESSAGE",
+	}}
+	for _, post := range posts {
+		var recipients []string
+		for _, recipient := range post.Recipients {
+			recipients = append(recipients, string(recipient))
+		}
+		t.rows = append(t.rows, []string{
+			post.PostID[:12],
+			post.Timestamp.Format(time.RFC3339),
+			string(post.Sender),
+			strings.Join(recipients, ","),
+			statusNames[post.Status],
+			string(post.Visibility),
+			excerpt(post.Message),
+		})
+	}
+	return t
+}
+
+// excerpt shortens a message to fit on one line of a table.
+func excerpt(message string) string {
+	message = strings.Join(strings.Fields(message), " ")
+	runes := []rune(message)
+	if len(runes) <= 40 {
+		return message
+	}
+	return string(runes[:39]) + "…"
+}
+
+// listPosts lists the newest posts, no matter their status or visibility.
+func listPosts(ctx *context, args []string) error {
+	fs := ctx.flags("")
+	format := outputFlag(fs)
+	n := fs.Int("n", 50, "the number of posts to list")
+	offset := fs.Int("offset", 0, "the number of newer posts to skip")
+	err := ctx.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+	if *n <= 0 || *offset < 0 {
+		fmt.Fprintln(ctx.out, "-n must be positive and -offset must not be negative")
+		fs.Usage()
+		return ErrUsage
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	posts, err := db.ListPosts(*n, *offset)
+	if err != nil {
+		return err
+	}
+	return ctx.write(*format, posts, postTable(posts))
+}
+
+// showPost shows a post.
+func showPost(ctx *context, args []string) error {
+	fs := ctx.flags("<post id>")
+	format := outputFlag(fs)
+	err := ctx.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	post, err := db.GetPost(fs.Arg(0), models.Viewer{Moderator: true})
+	if err != nil {
+		return err
+	}
+
+	// A single post is shown in full
+	t := postTable([]models.Post{post})
+	t.rows[0][0] = post.PostID
+	t.rows[0][6] = post.Message
+	return ctx.write(*format, post, t)
+}
+
+// deletePost deletes a post after asking for confirmation.
+func deletePost(ctx *context, args []string) error {
+	fs := ctx.flags("<post id>")
+	yes := yesFlag(fs)
+	err := ctx.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	postID := fs.Arg(0)
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	post, err := db.GetPost(postID, models.Viewer{Moderator: true})
+	if err != nil {
+		return err
+	}
+	if !*yes && !ctx.confirm("delete post %s by %s (%s)?",
+		postID, post.Sender, strconv.Quote(excerpt(post.Message))) {
+		fmt.Fprintln(ctx.out, "aborted")
+		return nil
+	}
+
+	err = db.DeletePost(postID)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "deleted post %s\n", postID)
+	return nil
+}
@@ -0,0 +1,85 @@
+package cli
+
+import "fmt"
+
+var schemaCommand = &command{
+	name:    "schema",
+	summary: "manage the database schema",
+	subcommands: []*command{
+		{
+			name:    "create",
+			summary: "create the database schema",
+			run:     createSchema,
+		},
+		{
+			name:    "migrate",
+			summary: "bring the schema of an existing database up to date",
+			run:     migrateSchema,
+		},
+		{
+			name:    "add-seniors",
+			summary: "add the seniors in seniors.txt to the database",
+			run:     addSeniors,
+		},
+	},
+}
+
+// createSchema creates the database schema.
+func createSchema(ctx *context, args []string) error {
+	err := ctx.parse(ctx.flags(""), args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	err = db.CreateSchema()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.out, "created schema")
+	return nil
+}
+
+// migrateSchema brings the database schema up to date.
+func migrateSchema(ctx *context, args []string) error {
+	err := ctx.parse(ctx.flags(""), args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	err = db.MigrateSchema()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.out, "migrated schema")
+	return nil
+}
+
+// addSeniors adds the seniors to the database.
+func addSeniors(ctx *context, args []string) error {
+	err := ctx.parse(ctx.flags(""), args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	err = db.AddSeniors()
+	if err != nil {
+		return err
+	}
+	fmt.Fprintln(ctx.out, "added the seniors to the database")
+	return nil
+}
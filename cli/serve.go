@@ -0,0 +1,29 @@
+package cli
+
+import (
+	"github.com/mattnappo/yearbook/api"
+	"github.com/mattnappo/yearbook/common"
+)
+
+var serveCommand = &command{
+	name:    "serve",
+	summary: "start the API server",
+	run:     serve,
+}
+
+// serve starts the API server. Every key of the config that is not a
+// secret can be set with a flag.
+func serve(ctx *context, args []string) error {
+	fs := ctx.flags("")
+	common.RegisterConfigFlags(fs)
+	err := ctx.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	config, err := ctx.config()
+	if err != nil {
+		return err
+	}
+	return api.StartAPIServer(config)
+}
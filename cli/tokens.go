@@ -0,0 +1,48 @@
+package cli
+
+import (
+	"fmt"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+var tokensCommand = &command{
+	name:    "tokens",
+	summary: "manage OAuth tokens",
+	subcommands: []*command{
+		{
+			name:    "revoke",
+			args:    "<username>",
+			summary: "revoke a user's token, signing them out",
+			run:     revokeToken,
+		},
+	},
+}
+
+// revokeToken revokes a user's token after asking for confirmation.
+func revokeToken(ctx *context, args []string) error {
+	fs := ctx.flags("<username>")
+	yes := yesFlag(fs)
+	err := ctx.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	username := models.Username(fs.Arg(0))
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	if !*yes && !ctx.confirm("revoke the token of %s?", username) {
+		fmt.Fprintln(ctx.out, "aborted")
+		return nil
+	}
+
+	err = db.RevokeToken(username.Email())
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "revoked the token of %s\n", username)
+	return nil
+}
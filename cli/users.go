@@ -0,0 +1,154 @@
+package cli
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+var usersCommand = &command{
+	name:    "users",
+	summary: "manage users",
+	subcommands: []*command{
+		{
+			name:    "list",
+			summary: "list every user",
+			run:     listUsers,
+		},
+		{
+			name:    "show",
+			args:    "<username>",
+			summary: "show a user",
+			run:     showUser,
+		},
+		{
+			name:    "delete",
+			args:    "<username>",
+			summary: "delete a user",
+			run:     deleteUser,
+		},
+		{
+			name:    "set-grade",
+			args:    "<username> <grade>",
+			summary: "set the grade of a user",
+			run:     setUserGrade,
+		},
+	},
+}
+
+// userTable makes the table of a list of users.
+func userTable(users []models.User) table {
+	t := table{headers: []string{
+		"USERNAME", "NAME", "GRADE", "ROLE", "REGISTERED", "INBOUND", "OUTBOUND",
+	}}
+	for _, user := range users {
+		t.rows = append(t.rows, []string{
+			string(user.Username),
+			user.Firstname + " " + user.Lastname,
+			user.Grade.String(),
+			strconv.Itoa(int(user.Role)),
+			strconv.FormatBool(user.Registered),
+			strconv.Itoa(len(user.InboundPosts)),
+			strconv.Itoa(len(user.OutboundPosts)),
+		})
+	}
+	return t
+}
+
+// listUsers lists every user.
+func listUsers(ctx *context, args []string) error {
+	fs := ctx.flags("")
+	format := outputFlag(fs)
+	err := ctx.parse(fs, args, 0)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	users, err := db.GetAllUsers()
+	if err != nil {
+		return err
+	}
+	return ctx.write(*format, users, userTable(users))
+}
+
+// showUser shows a user.
+func showUser(ctx *context, args []string) error {
+	fs := ctx.flags("<username>")
+	format := outputFlag(fs)
+	err := ctx.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	user, err := db.GetUser(fs.Arg(0))
+	if err != nil {
+		return err
+	}
+	return ctx.write(*format, user, userTable([]models.User{user}))
+}
+
+// deleteUser deletes a user after asking for confirmation.
+func deleteUser(ctx *context, args []string) error {
+	fs := ctx.flags("<username>")
+	yes := yesFlag(fs)
+	err := ctx.parse(fs, args, 1)
+	if err != nil {
+		return err
+	}
+	username := fs.Arg(0)
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	if !*yes && !ctx.confirm("delete user %s?", username) {
+		fmt.Fprintln(ctx.out, "aborted")
+		return nil
+	}
+
+	err = db.DeleteUser(username)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "deleted user %s\n", username)
+	return nil
+}
+
+// setUserGrade sets the grade of a user.
+func setUserGrade(ctx *context, args []string) error {
+	fs := ctx.flags("<username> <grade>")
+	err := ctx.parse(fs, args, 2)
+	if err != nil {
+		return err
+	}
+	username := fs.Arg(0)
+
+	db, err := ctx.database()
+	if err != nil {
+		return err
+	}
+
+	grade, err := models.ParseGrade(fs.Arg(1))
+	if err != nil {
+		return err
+	}
+
+	err = db.SetUserGrade(username, grade)
+	if err != nil {
+		return err
+	}
+	fmt.Fprintf(ctx.out, "set the grade of %s to %s\n", username, grade)
+	return nil
+}
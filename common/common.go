@@ -1,28 +1,17 @@
 package common
 
 import (
-	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 	"strings"
 	"unicode/utf8"
-
-	"github.com/joho/godotenv"
 )
 
 const (
 	// EmailSuffix is the accepted email suffix.
 	EmailSuffix = "@mastersny.org"
 
-	// MaxRecipients is the maximum amount of recipients on one post.
-	MaxRecipients = 10
-
-	// MaxImages is the maximum amount of images on one post.
-	MaxImages = 5
-
-	// MaxMessageLength is the maximum amount of characters in a post message.
-	MaxMessageLength = 2000
-
 	// MaxEmailLength is the maximum amount of characters in an email.
 	MaxEmailLength = 255
 
@@ -31,25 +20,20 @@ const (
 
 	// APIPort represents the default api server port
 	APIPort = 8081
-
-	// envFile is the path to the file containing needed environment variables.
-	envFile = "./.env"
-
-	// Email notification info
-	NotifEmail    = "mastersseniors2020.com@gmail.com"
-	NotifProvider = "smtp.gmail.com"
-	NotifPort     = 587
 )
 
-var (
-	// DatabaseName is the name of the Postgres database.
-	DatabaseName = GetEnv("DATABASE_NAME")
+// ModerationPolicy decides which new posts are held for review.
+type ModerationPolicy string
+
+const (
+	// ApproveAll publishes every post immediately.
+	ApproveAll ModerationPolicy = "approve-all"
 
-	// NotifPassword is the password for the gmail account that sends notifications.
-	NotifPassword = GetEnv("NOTIF_PASSWORD")
+	// HoldAll holds every post for review by a moderator.
+	HoldAll ModerationPolicy = "hold-all"
 
-	// NotifsEnabled turns email notifications on or off.
-	NotifsEnabled = false
+	// HoldFlagged holds only the posts flagged by the content filter.
+	HoldFlagged ModerationPolicy = "hold-flagged"
 )
 
 // CreateDirIfDoesNotExist creates a directory if it does not already exist.
@@ -64,16 +48,13 @@ func CreateDirIfDoesNotExist(dir string) error {
 	return nil
 }
 
-// GetEnv gets an environment variable
-func GetEnv(key string) string {
-	// Load .env file every time
-	err := godotenv.Load(envFile)
-
-	if err != nil {
-		panic(errors.New("could not load .env file"))
+// ParseModerationPolicy parses a moderation policy from a string.
+func ParseModerationPolicy(s string) (ModerationPolicy, error) {
+	switch policy := ModerationPolicy(s); policy {
+	case ApproveAll, HoldAll, HoldFlagged:
+		return policy, nil
 	}
-
-	return os.Getenv(key)
+	return "", fmt.Errorf("unknown moderation policy '%s'", s)
 }
 
 // StringToArray returns an array given a string.
@@ -1,7 +1,52 @@
 package common
 
-import "testing"
+import (
+	"flag"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
 
 func TestStringToArray(t *testing.T) {
 	t.Log(StringToArray("[\"a\", \"b\", \"c\"]"))
 }
+
+func TestLoadConfig(t *testing.T) {
+	dir, err := ioutil.TempDir("", "config")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	path := filepath.Join(dir, "config.env")
+	err = ioutil.WriteFile(path, []byte("DATABASE_NAME=file\nPORT=9000\nMAX_IMAGES=3\n"), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	os.Setenv("PORT", "9001")
+	defer os.Unsetenv("PORT")
+
+	fs := flag.NewFlagSet("test", flag.ContinueOnError)
+	RegisterConfigFlags(fs)
+	err = fs.Parse([]string{"-moderation", "hold-all"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	config, err := LoadConfig(path, fs)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if config.Database.Name != "file" || config.Port != 9001 ||
+		config.Limits.MaxImages != 3 || config.Moderation != HoldAll {
+		t.Fatalf("config was not loaded in order: %+v", config)
+	}
+
+	err = config.Validate()
+	if err == nil || !strings.Contains(err.Error(), "COOKIE_SECRET, DB_PASSWORD") ||
+		!strings.Contains(err.Error(), "MOD_EMAIL, NOTIF_FROM") {
+		t.Fatalf("expected every missing key to be listed, got %v", err)
+	}
+}
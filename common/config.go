@@ -0,0 +1,350 @@
+package common
+
+import (
+	"flag"
+	"fmt"
+	"os"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/joho/godotenv"
+)
+
+// DefaultConfigFile is the config file that is read if no other is given.
+// It is optional, since every key can be set in the environment instead.
+const DefaultConfigFile = "./.env"
+
+// Limits are the limits on the content of posts.
+type Limits struct {
+	// MaxRecipients is the maximum amount of recipients on one post.
+	MaxRecipients int
+
+	// MaxImages is the maximum amount of images on one post.
+	MaxImages int
+
+	// MaxMessageLength is the maximum amount of characters in a message.
+	MaxMessageLength int
+}
+
+// DefaultLimits are the limits used when none are configured.
+var DefaultLimits = Limits{
+	MaxRecipients:    10,
+	MaxImages:        5,
+	MaxMessageLength: 2000,
+}
+
+// DatabaseConfig is the configuration of the Postgres connection.
+type DatabaseConfig struct {
+	Name     string
+	User     string
+	Password string
+	Addr     string
+}
+
+// MailConfig is the configuration of email notifications.
+type MailConfig struct {
+	// Enabled turns email notifications on or off.
+	Enabled bool
+
+	// Sink is a directory that emails are written to instead of being
+	// sent, if it is set.
+	Sink string
+
+	// The SMTP server and the account that sends notifications
+	SMTPHost string
+	SMTPPort int
+	From     string
+	Password string
+
+	// ModEmail is the address that is emailed when a post needs review.
+	ModEmail string
+
+	// UnsubscribeSecret is the key used to sign unsubscribe links.
+	UnsubscribeSecret string
+}
+
+// Config is the configuration of the yearbook server.
+type Config struct {
+	Database DatabaseConfig
+	Mail     MailConfig
+	Limits   Limits
+
+	// Port is the port the API server listens on.
+	Port int64
+
+	// The timeouts of the API server. Event streams are cut off after the
+	// write timeout, and clients resume them by reconnecting.
+	ReadTimeout  time.Duration
+	WriteTimeout time.Duration
+	IdleTimeout  time.Duration
+
+	// ShutdownTimeout is how long in-flight requests are given to finish
+	// when the API server shuts down.
+	ShutdownTimeout time.Duration
+
+	// The frontend, which OAuth2 redirects back to
+	Protocol string
+	Provider string
+
+	// The Google OAuth2 client
+	GoogleClientID     string
+	GoogleClientSecret string
+
+	// CookieSecret is the key used to sign session cookies.
+	CookieSecret string
+
+	// Moderation is the moderation policy applied to new posts.
+	Moderation ModerationPolicy
+
+	// ReactionEmojis is the set of emojis that users can react to posts
+	// with.
+	ReactionEmojis []string
+
+	// ReportThreshold is the number of open reports it takes to hide a
+	// post until a moderator reviews it.
+	ReportThreshold int
+
+	// AnalyticsRetention is how long raw usage events are kept after they
+	// are added to the daily usage totals.
+	AnalyticsRetention time.Duration
+
+	// MetricsAddr is the address that metrics are served on, apart from
+	// the API. If it is not set, metrics are served on the API server, but
+	// only if MetricsToken is set.
+	MetricsAddr string
+
+	// MetricsToken is the bearer token required to read the metrics.
+	MetricsToken string
+}
+
+// DefaultConfig returns the configuration used for every key that is not
+// set. Secrets have no default.
+func DefaultConfig() *Config {
+	return &Config{
+		Database: DatabaseConfig{
+			User: "postgres",
+			Addr: "localhost:5432",
+		},
+		Mail: MailConfig{
+			SMTPHost: "smtp.gmail.com",
+			SMTPPort: 587,
+		},
+		Limits:             DefaultLimits,
+		Port:               APIPort,
+		ReadTimeout:        15 * time.Second,
+		WriteTimeout:       time.Minute,
+		IdleTimeout:        2 * time.Minute,
+		ShutdownTimeout:    30 * time.Second,
+		Moderation:         ApproveAll,
+		ReactionEmojis:     []string{"❤️", "🎉", "😂", "😢", "👏"},
+		ReportThreshold:    3,
+		AnalyticsRetention: 30 * 24 * time.Hour,
+	}
+}
+
+// secretKeys are the keys that can not be set with a command line flag,
+// so that secrets do not show up in the process list.
+var secretKeys = map[string]bool{
+	"DB_PASSWORD":          true,
+	"NOTIF_PASSWORD":       true,
+	"UNSUBSCRIBE_SECRET":   true,
+	"GOOGLE_CLIENT_SECRET": true,
+	"COOKIE_SECRET":        true,
+	"METRICS_TOKEN":        true,
+}
+
+// settings binds every key of the config to the field it sets. The flags
+// of the returned set are named by their keys.
+func (c *Config) settings() *flag.FlagSet {
+	fs := flag.NewFlagSet("config", flag.ContinueOnError)
+	c.Database.bind(fs)
+
+	fs.BoolVar(&c.Mail.Enabled, "NOTIFS_ENABLED", c.Mail.Enabled, "enable email notifications")
+	fs.StringVar(&c.Mail.Sink, "MAIL_SINK", c.Mail.Sink, "write emails to this directory instead of sending them")
+	fs.StringVar(&c.Mail.SMTPHost, "SMTP_HOST", c.Mail.SMTPHost, "the SMTP server that sends emails")
+	fs.IntVar(&c.Mail.SMTPPort, "SMTP_PORT", c.Mail.SMTPPort, "the port of the SMTP server")
+	fs.StringVar(&c.Mail.From, "NOTIF_FROM", c.Mail.From, "the address that emails are sent from")
+	fs.StringVar(&c.Mail.Password, "NOTIF_PASSWORD", c.Mail.Password, "the password of the address that emails are sent from")
+	fs.StringVar(&c.Mail.ModEmail, "MOD_EMAIL", c.Mail.ModEmail, "the address that is emailed when a post needs review")
+	fs.StringVar(&c.Mail.UnsubscribeSecret, "UNSUBSCRIBE_SECRET", c.Mail.UnsubscribeSecret, "the key used to sign unsubscribe links")
+
+	fs.IntVar(&c.Limits.MaxRecipients, "MAX_RECIPIENTS", c.Limits.MaxRecipients, "the maximum amount of recipients on one post")
+	fs.IntVar(&c.Limits.MaxImages, "MAX_IMAGES", c.Limits.MaxImages, "the maximum amount of images on one post")
+	fs.IntVar(&c.Limits.MaxMessageLength, "MAX_MESSAGE_LENGTH", c.Limits.MaxMessageLength, "the maximum amount of characters in a message")
+
+	fs.Int64Var(&c.Port, "PORT", c.Port, "the port to listen on")
+	fs.DurationVar(&c.ReadTimeout, "READ_TIMEOUT", c.ReadTimeout, "the longest time spent reading a request")
+	fs.DurationVar(&c.WriteTimeout, "WRITE_TIMEOUT", c.WriteTimeout, "the longest time spent writing a response")
+	fs.DurationVar(&c.IdleTimeout, "IDLE_TIMEOUT", c.IdleTimeout, "how long an idle keep-alive connection is kept open")
+	fs.DurationVar(&c.ShutdownTimeout, "SHUTDOWN_TIMEOUT", c.ShutdownTimeout, "how long in-flight requests are given to finish on shutdown")
+	fs.StringVar(&c.Protocol, "PROTOCOL", c.Protocol, "the protocol of the frontend (http or https)")
+	fs.StringVar(&c.Provider, "PROVIDER", c.Provider, "the host of the frontend")
+	fs.StringVar(&c.GoogleClientID, "GOOGLE_CLIENT_ID", c.GoogleClientID, "the Google OAuth2 client ID")
+	fs.StringVar(&c.GoogleClientSecret, "GOOGLE_CLIENT_SECRET", c.GoogleClientSecret, "the Google OAuth2 client secret")
+	fs.StringVar(&c.CookieSecret, "COOKIE_SECRET", c.CookieSecret, "the key used to sign session cookies")
+	fs.Var((*policyValue)(&c.Moderation), "MODERATION", "moderation policy (approve-all, hold-all, or hold-flagged)")
+	fs.Var((*listValue)(&c.ReactionEmojis), "REACTIONS", "comma-separated emojis that posts can be reacted to with")
+	fs.IntVar(&c.ReportThreshold, "REPORT_THRESHOLD", c.ReportThreshold, "number of reports it takes to hide a post")
+	fs.DurationVar(&c.AnalyticsRetention, "ANALYTICS_RETENTION", c.AnalyticsRetention, "how long raw usage events are kept after they are aggregated")
+	fs.StringVar(&c.MetricsAddr, "METRICS_ADDR", c.MetricsAddr, "serve metrics on this address (such as 127.0.0.1:9090) instead of the API server")
+	fs.StringVar(&c.MetricsToken, "METRICS_TOKEN", c.MetricsToken, "the bearer token required to read the metrics")
+	return fs
+}
+
+// bind binds the keys of the database config to its fields.
+func (c *DatabaseConfig) bind(fs *flag.FlagSet) {
+	fs.StringVar(&c.Name, "DATABASE_NAME", c.Name, "the name of the Postgres database")
+	fs.StringVar(&c.User, "DB_USER", c.User, "the Postgres user")
+	fs.StringVar(&c.Password, "DB_PASSWORD", c.Password, "the password of the Postgres user")
+	fs.StringVar(&c.Addr, "DB_ADDR", c.Addr, "the address of the Postgres server")
+}
+
+// flagName returns the name of the command line flag that sets a key.
+func flagName(key string) string {
+	return strings.ToLower(strings.ReplaceAll(key, "_", "-"))
+}
+
+// RegisterConfigFlags adds a flag to fs for every key of the config that
+// is not a secret. The flags are read by LoadConfig.
+func RegisterConfigFlags(fs *flag.FlagSet) {
+	DefaultConfig().settings().VisitAll(func(f *flag.Flag) {
+		if !secretKeys[f.Name] {
+			fs.Var(f.Value, flagName(f.Name), f.Usage)
+		}
+	})
+}
+
+// LoadConfig loads the config from a config file, then the environment,
+// then the flags that were set in fs, each overriding the last. The config
+// file is in the .env format and is skipped if it does not exist and is the
+// default. fs may be nil. The config is not validated.
+func LoadConfig(path string, fs *flag.FlagSet) (*Config, error) {
+	config := DefaultConfig()
+	settings := config.settings()
+
+	file, err := godotenv.Read(path)
+	if os.IsNotExist(err) && path == DefaultConfigFile {
+		file, err = nil, nil
+	}
+	if err != nil {
+		return nil, fmt.Errorf("could not read config file: %v", err)
+	}
+
+	var errs []string
+	set := func(key, value, source string) {
+		err := settings.Set(key, value)
+		if err != nil {
+			errs = append(errs, fmt.Sprintf("%s from %s: %v", key, source, err))
+		}
+	}
+	settings.VisitAll(func(f *flag.Flag) {
+		if value, ok := file[f.Name]; ok {
+			set(f.Name, value, path)
+		}
+		if value, ok := os.LookupEnv(f.Name); ok {
+			set(f.Name, value, "the environment")
+		}
+	})
+	if fs != nil {
+		settings.VisitAll(func(f *flag.Flag) {
+			flag := fs.Lookup(flagName(f.Name))
+			if flag != nil && isSet(fs, flag.Name) {
+				set(f.Name, flag.Value.String(), "-"+flag.Name)
+			}
+		})
+	}
+
+	if len(errs) > 0 {
+		return nil, fmt.Errorf("invalid config: %s", strings.Join(errs, "; "))
+	}
+	return config, nil
+}
+
+// isSet returns whether a flag was set on the command line.
+func isSet(fs *flag.FlagSet, name string) bool {
+	set := false
+	fs.Visit(func(f *flag.Flag) {
+		if f.Name == name {
+			set = true
+		}
+	})
+	return set
+}
+
+// Validate checks that every key the API server needs is set. The error
+// lists every missing key.
+func (c *Config) Validate() error {
+	missing := c.Database.missing()
+	for key, value := range map[string]string{
+		"PROTOCOL":             c.Protocol,
+		"PROVIDER":             c.Provider,
+		"GOOGLE_CLIENT_ID":     c.GoogleClientID,
+		"GOOGLE_CLIENT_SECRET": c.GoogleClientSecret,
+		"COOKIE_SECRET":        c.CookieSecret,
+		"UNSUBSCRIBE_SECRET":   c.Mail.UnsubscribeSecret,
+		"NOTIF_FROM":           c.Mail.From,
+		"MOD_EMAIL":            c.Mail.ModEmail,
+	} {
+		if value == "" {
+			missing = append(missing, key)
+		}
+	}
+
+	// The password is only needed to actually send emails
+	if c.Mail.Enabled && c.Mail.Sink == "" && c.Mail.Password == "" {
+		missing = append(missing, "NOTIF_PASSWORD")
+	}
+	return missingError(missing)
+}
+
+// Validate checks that every key needed to connect to the database is set.
+// The error lists every missing key.
+func (c *DatabaseConfig) Validate() error {
+	return missingError(c.missing())
+}
+
+// missing returns the keys of the database config that are not set.
+func (c *DatabaseConfig) missing() []string {
+	var missing []string
+	if c.Name == "" {
+		missing = append(missing, "DATABASE_NAME")
+	}
+	if c.Password == "" {
+		missing = append(missing, "DB_PASSWORD")
+	}
+	return missing
+}
+
+// missingError makes the error reporting a list of missing keys.
+func missingError(missing []string) error {
+	if len(missing) == 0 {
+		return nil
+	}
+	sort.Strings(missing)
+	return fmt.Errorf("missing config keys: %s", strings.Join(missing, ", "))
+}
+
+// policyValue is a moderation policy that can be set as a flag.
+type policyValue ModerationPolicy
+
+func (p *policyValue) String() string { return string(*p) }
+
+func (p *policyValue) Set(s string) error {
+	policy, err := ParseModerationPolicy(s)
+	if err != nil {
+		return err
+	}
+	*p = policyValue(policy)
+	return nil
+}
+
+// listValue is a comma-separated list that can be set as a flag.
+type listValue []string
+
+func (l *listValue) String() string { return strings.Join(*l, ",") }
+
+func (l *listValue) Set(s string) error {
+	*l = strings.Split(s, ",")
+	return nil
+}
@@ -3,9 +3,12 @@ package crypto
 /* sha3 code was adapted from @dowlandaiello */
 
 import (
+	"crypto/hmac"
 	"crypto/rand"
 	"encoding/base64"
 	"encoding/hex"
+	"errors"
+	"strings"
 
 	"golang.org/x/crypto/sha3"
 )
@@ -19,6 +22,13 @@ const (
 	tokenSize = 64
 )
 
+// errInvalidToken is returned when a signed token cannot be verified.
+var errInvalidToken = errors.New("invalid signed token")
+
+// errEmptyKey is returned when signing or verifying a token without a key,
+// since anyone could forge a token signed with an empty key.
+var errEmptyKey = errors.New("the signing key is empty")
+
 // Hash represents the streamlined hash type to be used.
 type Hash [hashLength]byte
 
@@ -90,3 +100,47 @@ func GenRandomToken() string {
 	rand.Read(b)
 	return base64.StdEncoding.EncodeToString(b)
 }
+
+// Sign signs a message with a secret key using HMAC-SHA3.
+func Sign(key, message []byte) Hash {
+	mac := hmac.New(sha3.New256, key)
+	mac.Write(message)
+	return newHash(mac.Sum(nil))
+}
+
+// NewSignedToken constructs a URL-safe token carrying a payload that can
+// only be produced by someone who knows the secret key.
+func NewSignedToken(key []byte, payload string) (string, error) {
+	if len(key) == 0 {
+		return "", errEmptyKey
+	}
+	encoded := base64.RawURLEncoding.EncodeToString([]byte(payload))
+	return encoded + "." + Sign(key, []byte(encoded)).String(), nil
+}
+
+// VerifySignedToken checks the signature of a token made with
+// NewSignedToken and returns its payload.
+func VerifySignedToken(key []byte, token string) (string, error) {
+	if len(key) == 0 {
+		return "", errEmptyKey
+	}
+	parts := strings.Split(token, ".")
+	if len(parts) != 2 {
+		return "", errInvalidToken
+	}
+
+	signature, err := hex.DecodeString(parts[1])
+	if err != nil {
+		return "", errInvalidToken
+	}
+	expected := Sign(key, []byte(parts[0]))
+	if !hmac.Equal(signature, expected.Bytes()) {
+		return "", errInvalidToken
+	}
+
+	payload, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return "", errInvalidToken
+	}
+	return string(payload), nil
+}
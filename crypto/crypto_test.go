@@ -0,0 +1,33 @@
+package crypto
+
+import "testing"
+
+func TestSignedToken(t *testing.T) {
+	key := []byte("secret")
+	token, err := NewSignedToken(key, "first.last|new_post")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	payload, err := VerifySignedToken(key, token)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload != "first.last|new_post" {
+		t.Fatalf("unexpected payload %s", payload)
+	}
+
+	_, err = VerifySignedToken([]byte("wrong"), token)
+	if err == nil {
+		t.Fatal("token verified with the wrong key")
+	}
+
+	_, err = NewSignedToken(nil, "first.last|new_post")
+	if err == nil {
+		t.Fatal("token signed with an empty key")
+	}
+	_, err = VerifySignedToken(nil, token)
+	if err == nil {
+		t.Fatal("token verified with an empty key")
+	}
+}
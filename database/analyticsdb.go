@@ -0,0 +1,127 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// reportDays is the number of days covered by a usage report.
+const reportDays = 30
+
+// usage is the daily usage totals along with the usage events that are
+// not in them yet, so that reports include today.
+const usage = `WITH usage AS (
+	SELECT day, username, method, route, requests FROM daily_usages
+	UNION ALL
+	SELECT date_trunc('day', timestamp) AS day, username, method, route,
+		count(*) AS requests
+	FROM usage_events
+	WHERE NOT aggregated
+	GROUP BY 1, 2, 3, 4
+)`
+
+// daysAgo is the start of the day some number of days before ?0, to be
+// followed by the number of days.
+const daysAgo = `date_trunc('day', ?0::timestamptz) - interval '1 day' * `
+
+// AddUsageEvents adds a batch of usage events.
+func (db *Database) AddUsageEvents(events []*models.UsageEvent) error {
+	if len(events) == 0 {
+		return nil
+	}
+	_, err := db.DB.Model(&events).Insert()
+	return err
+}
+
+// AggregateUsage adds the usage events from before the given day to the
+// daily usage totals, and then deletes the counted events that are older
+// than the retention period.
+func (db *Database) AggregateUsage(
+	before time.Time,
+	retention time.Duration,
+) error {
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		// Mark and count the events in one statement, so that events added
+		// in between are neither counted without being marked nor marked
+		// without being counted
+		_, err := tx.Exec(`
+			WITH moved AS (
+				UPDATE usage_events SET aggregated = true
+				WHERE NOT aggregated AND timestamp < ?0
+				RETURNING timestamp, username, method, route
+			)
+			INSERT INTO daily_usages (day, username, method, route, requests)
+			SELECT date_trunc('day', timestamp), username, method, route,
+				count(*)
+			FROM moved
+			GROUP BY 1, 2, 3, 4
+			ON CONFLICT (day, username, method, route) DO UPDATE
+			SET requests = daily_usages.requests + EXCLUDED.requests`,
+			before,
+		)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.Model((*models.UsageEvent)(nil)).
+			Where("aggregated").
+			Where("timestamp < ?", time.Now().Add(-retention)).
+			Delete()
+		return err
+	})
+}
+
+// GetUsageReport gets the daily, weekly and monthly active users, the
+// active users of each of the last 30 days, and the most popular routes.
+func (db *Database) GetUsageReport(now time.Time) (*models.UsageReport, error) {
+	report := &models.UsageReport{
+		DailyActive: []models.ActiveDay{},
+		Routes:      []models.RouteUsage{},
+	}
+	var active struct {
+		DAU int
+		WAU int
+		MAU int
+	}
+	_, err := db.DB.QueryOne(&active, usage+`
+		SELECT
+			count(DISTINCT username) FILTER (WHERE day >= `+daysAgo+`0) AS dau,
+			count(DISTINCT username) FILTER (WHERE day >= `+daysAgo+`6) AS wau,
+			count(DISTINCT username) FILTER (WHERE day >= `+daysAgo+`?1) AS mau
+		FROM usage`,
+		now, reportDays-1,
+	)
+	if err != nil {
+		return nil, err
+	}
+	report.DAU, report.WAU, report.MAU = active.DAU, active.WAU, active.MAU
+
+	_, err = db.DB.Query(&report.DailyActive, usage+`
+		SELECT day, count(DISTINCT username) AS users
+		FROM usage
+		WHERE day >= `+daysAgo+`?1
+		GROUP BY day
+		ORDER BY day ASC`,
+		now, reportDays-1,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	_, err = db.DB.Query(&report.Routes, usage+`
+		SELECT method, route, sum(requests) AS requests,
+			count(DISTINCT username) AS users
+		FROM usage
+		WHERE day >= `+daysAgo+`?1
+		GROUP BY method, route
+		ORDER BY requests DESC, route ASC`,
+		now, reportDays-1,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	return report, nil
+}
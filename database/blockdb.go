@@ -0,0 +1,82 @@
+package database
+
+import (
+	"github.com/mattnappo/yearbook/models"
+)
+
+// BlockUser stops a user from posting about another user.
+func (db *Database) BlockUser(blocker, blocked models.Username) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, err := db.GetUser(string(blocker))
+	if err != nil {
+		return err
+	}
+	if user.HasBlocked(blocked) {
+		return nil
+	}
+
+	_, err = db.DB.Model((*models.User)(nil)).
+		Set("blocked = ?", append(user.Blocked, blocked)).
+		Where("username = ?", string(blocker)).
+		Update()
+	return err
+}
+
+// UnblockUser allows a blocked user to post about another user again.
+func (db *Database) UnblockUser(blocker, blocked models.Username) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	user, err := db.GetUser(string(blocker))
+	if err != nil {
+		return err
+	}
+
+	// Remove the blocked user from the slice
+	newBlocked := []models.Username{}
+	for _, username := range user.Blocked {
+		if username != blocked {
+			newBlocked = append(newBlocked, username)
+		}
+	}
+
+	_, err = db.DB.Model((*models.User)(nil)).
+		Set("blocked = ?", newBlocked).
+		Where("username = ?", string(blocker)).
+		Update()
+	return err
+}
+
+// GetBlocked gets the users that a user has blocked.
+func (db *Database) GetBlocked(username string) ([]models.Username, error) {
+	user, err := db.GetUser(username)
+	if err != nil {
+		return nil, err
+	}
+	return user.Blocked, nil
+}
+
+// SetDoNotAcceptPosts turns a user's "do not accept posts" setting on or
+// off.
+func (db *Database) SetDoNotAcceptPosts(username string, enabled bool) error {
+	_, err := db.DB.Model((*models.User)(nil)).
+		Set("do_not_accept_posts = ?", enabled).
+		Where("username = ?", username).
+		Update()
+	return err
+}
+
+// GetUsers gets the users with the given usernames. Usernames that are not
+// in the database are skipped.
+func (db *Database) GetUsers(usernames []string) ([]models.User, error) {
+	var users []models.User
+	if len(usernames) == 0 {
+		return users, nil
+	}
+	err := db.DB.Model(&users).
+		WhereIn("username IN (?)", usernames).
+		Select()
+	return users, err
+}
@@ -0,0 +1,110 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// AddComment adds a comment to a post.
+func (db *Database) AddComment(comment *models.Comment) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	return db.DB.Insert(comment)
+}
+
+// GetComment gets a comment from the database.
+func (db *Database) GetComment(id int64) (models.Comment, error) {
+	var comment models.Comment
+	err := db.DB.Model(&comment).
+		Where("id = ?", id).
+		Select()
+	return comment, err
+}
+
+// GetComments gets n of the top-level comments on a post at a certain
+// offset, oldest first, each with all of its replies.
+func (db *Database) GetComments(
+	postID string,
+	n, offset int,
+) ([]models.Comment, error) {
+	comments := []models.Comment{}
+	err := db.DB.Model(&comments).
+		Where("post_id = ?", postID).
+		Where("parent_id IS NULL").
+		Order("timestamp ASC", "id ASC").
+		Limit(n).
+		Offset(offset).
+		Select()
+	if err != nil || len(comments) == 0 {
+		return comments, err
+	}
+
+	var parentIDs []int64
+	index := make(map[int64]int)
+	for i := range comments {
+		comments[i].Replies = []models.Comment{}
+		parentIDs = append(parentIDs, comments[i].ID)
+		index[comments[i].ID] = i
+	}
+
+	// Get the replies to this page of comments
+	var replies []models.Comment
+	err = db.DB.Model(&replies).
+		WhereIn("parent_id IN (?)", parentIDs).
+		Order("timestamp ASC", "id ASC").
+		Select()
+	if err != nil {
+		return nil, err
+	}
+	for _, reply := range replies {
+		i := index[reply.ParentID]
+		comments[i].Replies = append(comments[i].Replies, reply)
+	}
+
+	return comments, nil
+}
+
+// GetNumComments gets the number of top-level comments on a post.
+func (db *Database) GetNumComments(postID string) (int, error) {
+	return db.DB.Model((*models.Comment)(nil)).
+		Where("post_id = ?", postID).
+		Where("parent_id IS NULL").
+		Count()
+}
+
+// EditComment replaces the message of a comment.
+func (db *Database) EditComment(
+	id int64,
+	message string,
+	editedAt time.Time,
+) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	res, err := db.DB.Model((*models.Comment)(nil)).
+		Set("message = ?", message).
+		Set("edited_at = ?", editedAt).
+		Where("id = ?", id).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
+// DeleteComment deletes a comment along with all of its replies.
+func (db *Database) DeleteComment(id int64) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	_, err := db.DB.Model((*models.Comment)(nil)).
+		Where("id = ?", id).
+		WhereOr("parent_id = ?", id).
+		Delete()
+	return err
+}
@@ -1,23 +1,46 @@
 package database
 
 import (
-	"github.com/go-pg/pg"
+	pgv8 "github.com/go-pg/pg"
+	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
 	"github.com/mattnappo/yearbook/models"
 )
 
-// AddPost adds a post to the database.
-func (db *Database) AddPost(post *models.Post) error {
+// AddPost adds a post to the database, along with the notifications about
+// it, in one transaction.
+func (db *Database) AddPost(
+	post *models.Post,
+	notifs ...*models.Notification,
+) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
-	err := db.DB.Insert(post)
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Insert(post)
+		if err != nil {
+			return err
+		}
+		return enqueue(tx, notifs)
+	})
+}
+
+// GetPost gets a post from the database if the viewer can see it.
+func (db *Database) GetPost(
+	postID string,
+	viewer models.Viewer,
+) (models.Post, error) {
+	post, err := db.getPost(postID)
 	if err != nil {
-		return err
+		return models.Post{}, err
 	}
-	return nil
+	if !post.VisibleTo(viewer) {
+		return models.Post{}, pg.ErrNoRows
+	}
+	return post, nil
 }
 
-// GetPost gets a post from the database.
-func (db *Database) GetPost(postID string) (models.Post, error) {
+// getPost gets a post from the database no matter who can see it.
+func (db *Database) getPost(postID string) (models.Post, error) {
 	post := &models.Post{}
 	err := db.DB.Model(post).
 		Where("post.post_id = ?", postID).
@@ -29,32 +52,49 @@ func (db *Database) GetPost(postID string) (models.Post, error) {
 	return *post, nil
 }
 
-// GetAllPosts gets all posts from the database.
-func (db *Database) GetAllPosts() ([]models.Post, error) {
+// GetAllPosts gets all approved posts that the viewer can see from the
+// database.
+func (db *Database) GetAllPosts(viewer models.Username) ([]models.Post, error) {
 	var posts []models.Post
-	err := db.DB.Model(&posts).Select()
+	err := db.DB.Model(&posts).
+		Where("status = ?", models.Approved).
+		WhereGroup(visibleTo(viewer)).
+		Select()
 	if err != nil {
 		return nil, err
 	}
 	return posts, nil
 }
 
-// GetnPosts gets n posts from the database.
-func (db *Database) GetnPosts(n int) ([]models.Post, error) {
+// GetnPosts gets n approved posts that the viewer can see from the
+// database.
+func (db *Database) GetnPosts(
+	n int,
+	viewer models.Username,
+) ([]models.Post, error) {
 	var posts []models.Post
-	err := db.DB.Model(&posts).Order("id DESC").Limit(n).Select()
+	err := db.DB.Model(&posts).
+		Where("status = ?", models.Approved).
+		WhereGroup(visibleTo(viewer)).
+		Order("id DESC").
+		Limit(n).
+		Select()
 	if err != nil {
 		return nil, err
 	}
 	return posts, nil
 }
 
-// GetnPostsWithOffset gets n posts at a ceratin offset.
+// GetnPostsWithOffset gets n approved posts that the viewer can see at a
+// ceratin offset.
 func (db *Database) GetnPostsWithOffset(
 	n, offset int,
+	viewer models.Username,
 ) ([]models.Post, error) {
 	var posts []models.Post
 	err := db.DB.Model(&posts).
+		Where("status = ?", models.Approved).
+		WhereGroup(visibleTo(viewer)).
 		Limit(n).
 		Offset(offset).
 		Order("id DESC").
@@ -63,9 +103,25 @@ func (db *Database) GetnPostsWithOffset(
 	return posts, err
 }
 
-// GetNumPosts returns the number of posts in the database.
-func (db *Database) GetNumPosts() (int, error) {
-	return db.DB.Model((*models.Post)(nil)).Count()
+// GetNumPosts returns the number of approved posts that the viewer can see
+// in the database.
+func (db *Database) GetNumPosts(viewer models.Username) (int, error) {
+	return db.DB.Model((*models.Post)(nil)).
+		Where("status = ?", models.Approved).
+		WhereGroup(visibleTo(viewer)).
+		Count()
+}
+
+// ListPosts gets n posts at a certain offset, newest first, no matter
+// their status or visibility. It is only for admin tools.
+func (db *Database) ListPosts(n, offset int) ([]models.Post, error) {
+	posts := []models.Post{}
+	err := db.DB.Model(&posts).
+		Order("id DESC").
+		Limit(n).
+		Offset(offset).
+		Select()
+	return posts, err
 }
 
 // removeInboundPost deletes the given postID from the slice of
@@ -106,7 +162,7 @@ func (db *Database) DeletePost(postID string) error {
 	defer db.mux.Unlock()
 
 	// Get the post. We are going to need some data from it.
-	post, err := db.GetPost(postID)
+	post, err := db.getPost(postID)
 	if err != nil {
 		return err
 	}
@@ -147,6 +203,22 @@ func (db *Database) DeletePost(postID string) error {
 		}
 	}
 
+	// Delete the comments on the post
+	_, err = db.DB.Model((*models.Comment)(nil)).
+		Where("post_id = ?", postID).
+		Delete()
+	if err != nil {
+		return err
+	}
+
+	// Delete the reactions to the post
+	_, err = db.DB.Model((*models.Reaction)(nil)).
+		Where("post_id = ?", postID).
+		Delete()
+	if err != nil {
+		return err
+	}
+
 	// Delete the post itself from the post database
 	_, err = db.DB.Model(&models.Post{}).
 		Where("post.post_id = ?", postID).
@@ -255,8 +327,12 @@ func (db *Database) GetUser(username string) (models.User, error) {
 	return *user, nil
 }
 
-// GetUserInbound returns the inbound posts of a user.
-func (db *Database) GetUserInbound(username string) ([]models.Post, error) {
+// GetUserInbound returns the approved inbound posts of a user that the
+// viewer can see.
+func (db *Database) GetUserInbound(
+	username string,
+	viewer models.Username,
+) ([]models.Post, error) {
 	var inboundPostIDs models.User
 
 	// Get the list of inbound postIDs
@@ -271,15 +347,20 @@ func (db *Database) GetUserInbound(username string) ([]models.Post, error) {
 	// Get all of the posts given the postIDs
 	var posts []models.Post
 	for _, inboundPostID := range inboundPostIDs.InboundPosts {
-		post, _ := db.GetPost(inboundPostID) // CHECK THIS ERROR SOMEHOW
+		post, _ := db.getPost(inboundPostID) // CHECK THIS ERROR SOMEHOW
 		posts = append(posts, post)
 	}
-	return posts, nil
+	return visibleOnly(approvedOnly(posts), viewer), nil
 }
 
 // GetUserInboundOutbound returns partial information about the inbound
-// and outbound posts of a user.
-func (db *Database) GetUserInboundOutbound(username string) ([][]models.Post, error) {
+// and outbound posts of a user that the viewer can see. Only approved
+// inbound posts are returned, but the user's own pending outbound posts
+// are returned to them.
+func (db *Database) GetUserInboundOutbound(
+	username string,
+	viewer models.Username,
+) ([][]models.Post, error) {
 	var postIDs models.User
 
 	// Get the list of inbound and outbound postIDs
@@ -293,8 +374,10 @@ func (db *Database) GetUserInboundOutbound(username string) ([][]models.Post, er
 
 	// Get all of the necessary post data given the post IDs
 	// Really these should throw errors
-	inboundPosts := db.traversePosts(postIDs.InboundPosts)
-	outboundPosts := db.traversePosts(postIDs.OutboundPosts)
+	inboundPosts := visibleOnly(
+		approvedOnly(db.traversePosts(postIDs.InboundPosts)), viewer,
+	)
+	outboundPosts := visibleOnly(db.traversePosts(postIDs.OutboundPosts), viewer)
 	return [][]models.Post{inboundPosts, outboundPosts}, nil
 }
 
@@ -367,14 +450,37 @@ func (db *Database) GetAllSeniorUsernames() ([]string, error) {
 	return usernames, err
 }
 
+// SetUserGrade sets the grade of a user.
+func (db *Database) SetUserGrade(username string, grade models.Grade) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	res, err := db.DB.Model((*models.User)(nil)).
+		Set("grade = ?", grade).
+		Where("username = ?", username).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
+
 // DeleteUser deletes a user from the database
 func (db *Database) DeleteUser(username string) error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
-	_, err := db.DB.Model(&models.User{}).
+	res, err := db.DB.Model(&models.User{}).
 		Where("username = ?", username).
 		Delete()
-	return err
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
 }
 
 // InitAccount initializes a new account.
@@ -391,7 +497,7 @@ func (db *Database) InitAccount(username, picture string) error {
 func checkIntegrity(err error) error {
 	// Return the error as long as it is not a duplicate key violation.
 	if err != nil {
-		pgErr, ok := err.(pg.Error)
+		pgErr, ok := err.(pgv8.Error)
 		if ok && pgErr.IntegrityViolation() {
 			return nil
 		}
@@ -405,7 +511,7 @@ func checkIntegrity(err error) error {
 func checkNoResults(err error) error {
 	// Return the error as long as it is not a no results in set error.
 	if err != nil {
-		pgErr, ok := err.(pg.Error)
+		pgErr, ok := err.(pgv8.Error)
 		if ok && pgErr.Field() == pg.ErrNoRows.Error() {
 			return nil
 		}
@@ -427,3 +533,37 @@ func (db *Database) traversePosts(postIDs []string) []models.Post {
 	}
 	return posts
 }
+
+// approvedOnly filters a slice of posts down to the approved posts.
+func approvedOnly(posts []models.Post) []models.Post {
+	var approved []models.Post
+	for _, post := range posts {
+		if post.Status == models.Approved {
+			approved = append(approved, post)
+		}
+	}
+	return approved
+}
+
+// visibleOnly filters a slice of posts down to the posts that the viewer
+// can see.
+func visibleOnly(posts []models.Post, viewer models.Username) []models.Post {
+	var visible []models.Post
+	for _, post := range posts {
+		if post.VisibleTo(models.Viewer{Username: viewer}) {
+			visible = append(visible, post)
+		}
+	}
+	return visible
+}
+
+// visibleTo builds the condition for a query to only select the posts
+// that the viewer can see.
+func visibleTo(viewer models.Username) func(*orm.Query) (*orm.Query, error) {
+	return func(q *orm.Query) (*orm.Query, error) {
+		q = q.Where("visibility = ?", models.Public).
+			WhereOr("visibility = ? AND sender = ?", models.Private, viewer).
+			WhereOr("recipients @> to_jsonb(?::text)", viewer)
+		return q, nil
+	}
+}
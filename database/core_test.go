@@ -3,9 +3,12 @@ package database
 import (
 	"fmt"
 	"math/rand"
+	"strings"
 	"testing"
 	"time"
 
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/common"
 	"github.com/mattnappo/yearbook/models"
 )
 
@@ -19,9 +22,22 @@ func genRandEmail() string {
 	return fmt.Sprintf("first%s.last%s@mastersny.org", r, r)
 }
 
+// connect connects to the test database.
+func connect(t *testing.T) *Database {
+	config, err := common.LoadConfig(common.DefaultConfigFile, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	db, err := Connect(config.Database)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return db
+}
+
 func TestAddPost(t *testing.T) {
 	rand.Seed(time.Now().UnixNano())
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	post, err := models.NewPost(
@@ -29,6 +45,7 @@ func TestAddPost(t *testing.T) {
 		"I am a message",
 		[]string{"dW5pcXVlIGltYWdlIGRhdGEgb25l", "dW5pcXVlIGltYWdlIGRhdGEgdHdv"},
 		[]string{genRandUser()},
+		common.DefaultLimits,
 	)
 	if err != nil {
 		t.Fatal(err)
@@ -41,10 +58,10 @@ func TestAddPost(t *testing.T) {
 
 func TestGetPost(t *testing.T) {
 	pid := "a6b9d19f01c0205d5da39b734902273384a5b493d422b6240f953ba521438c85"
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
-	post, err := db.GetPost(pid)
+	post, err := db.GetPost(pid, models.Viewer{Moderator: true})
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -52,10 +69,10 @@ func TestGetPost(t *testing.T) {
 }
 
 func TestGetAllPosts(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
-	posts, err := db.GetAllPosts()
+	posts, err := db.GetAllPosts("")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -66,10 +83,10 @@ func TestGetAllPosts(t *testing.T) {
 }
 
 func TestGetnPosts(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
-	posts, err := db.GetnPosts(5)
+	posts, err := db.GetnPosts(5, "")
 	if err != nil {
 		t.Fatal(err)
 	}
@@ -78,7 +95,7 @@ func TestGetnPosts(t *testing.T) {
 }
 
 func TestDeletePost(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	uid := "c10484d75eb65ba5aaaaf93a3457332a3c924198de07518573f99836bc31ddfe"
@@ -89,7 +106,7 @@ func TestDeletePost(t *testing.T) {
 }
 
 func TestAddUser(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	user, err := models.NewUser("cool.dude@mastersny.org", models.Sophomore, false)
@@ -104,7 +121,7 @@ func TestAddUser(t *testing.T) {
 }
 
 func TestUpdateUser(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	// models.NewUser("epic.man@mastersny.org", models.Sophomore, false)
@@ -121,7 +138,7 @@ func TestUpdateUser(t *testing.T) {
 }
 
 func TestAddToAndFrom(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	err := db.AddToAndFrom(
@@ -136,7 +153,7 @@ func TestAddToAndFrom(t *testing.T) {
 }
 
 func TestGetUser(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	uid := "first252744.last252744"
@@ -148,7 +165,7 @@ func TestGetUser(t *testing.T) {
 }
 
 func TestGetAllUsers(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	users, err := db.GetAllUsers()
@@ -160,7 +177,7 @@ func TestGetAllUsers(t *testing.T) {
 }
 
 func TestGetAllSeniorUsernames(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
 	usernames, err := db.GetAllSeniorUsernames()
@@ -172,22 +189,475 @@ func TestGetAllSeniorUsernames(t *testing.T) {
 }
 
 func TestDeleteUser(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
-	uid := "first252744.last252744"
-	err := db.DeleteUser(uid)
+	user, err := models.NewUser("first252744.last252744@mastersny.org", models.Senior, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddUser(user)
 	if err != nil {
 		t.Fatal(err)
 	}
+
+	err = db.DeleteUser(string(user.Username))
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.DeleteUser(string(user.Username))
+	if err != pg.ErrNoRows {
+		t.Fatalf("deleting a missing user returned %v", err)
+	}
 }
 
 func TestGetUserInboundOutbound(t *testing.T) {
-	db := Connect(false)
+	db := connect(t)
 	defer db.Disconnect()
 
-	_, err := db.GetUserInboundOutbound("matthew.nappo")
+	_, err := db.GetUserInboundOutbound("matthew.nappo", "matthew.nappo")
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+// visibilityCase is who can see a post of a visibility: the sender, the
+// recipient and someone else.
+type visibilityCase struct {
+	visibility models.Visibility
+	sender     bool
+	recipient  bool
+	stranger   bool
+}
+
+// visibilityCases are the visibility cases of every visibility.
+var visibilityCases = []visibilityCase{
+	{models.Public, true, true, true},
+	{models.RecipientsOnly, false, true, false},
+	{models.Private, true, true, false},
+}
+
+// visibilityViewers maps the viewers of a post to whether they can see it.
+func visibilityViewers(post *models.Post, c visibilityCase) map[models.Username]bool {
+	return map[models.Username]bool{
+		post.Sender:        c.sender,
+		post.Recipients[0]: c.recipient,
+		"some.one":         c.stranger,
+	}
+}
+
+// addVisiblePost adds an approved post with the given visibility from a
+// new sender to a new recipient.
+func addVisiblePost(
+	t *testing.T,
+	db *Database,
+	visibility models.Visibility,
+) *models.Post {
+	post, err := models.NewPost(
+		genRandUser(),
+		"I am a message",
+		[]string{},
+		[]string{genRandUser()},
+		common.DefaultLimits,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Status = models.Approved
+	post.Visibility = visibility
+
+	err = db.AddPost(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, username := range []models.Username{post.Sender, post.Recipients[0]} {
+		user, err := models.NewUser(username.Email(), models.Senior, false)
+		if err != nil {
+			t.Fatal(err)
+		}
+		err = db.AddUser(user)
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	err = db.AddToAndFrom(
+		post.PostID,
+		string(post.Sender),
+		[]string{string(post.Recipients[0])},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+// containsPost checks if a slice of posts contains a post.
+func containsPost(posts []models.Post, postID string) bool {
+	for _, post := range posts {
+		if post.PostID == postID {
+			return true
+		}
+	}
+	return false
+}
+
+func TestGetPostVisibility(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	for _, c := range visibilityCases {
+		post := addVisiblePost(t, db, c.visibility)
+
+		for viewer, visible := range visibilityViewers(post, c) {
+			_, err := db.GetPost(post.PostID, models.Viewer{Username: viewer})
+			if (err == nil) != visible {
+				t.Fatalf("%s post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+		}
+
+		_, err := db.GetPost(post.PostID, models.Viewer{Moderator: true})
+		if err != nil {
+			t.Fatalf("%s post not visible to moderators: %s", c.visibility, err)
+		}
+	}
+}
+
+func TestGetAllPostsVisibility(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	for _, c := range visibilityCases {
+		post := addVisiblePost(t, db, c.visibility)
+
+		for viewer, visible := range visibilityViewers(post, c) {
+			posts, err := db.GetAllPosts(viewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(posts, post.PostID) != visible {
+				t.Fatalf("%s post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+		}
+	}
+}
+
+func TestGetnPostsVisibility(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	for _, c := range visibilityCases {
+		post := addVisiblePost(t, db, c.visibility)
+
+		for viewer, visible := range visibilityViewers(post, c) {
+			posts, err := db.GetnPosts(1, viewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(posts, post.PostID) != visible {
+				t.Fatalf("%s post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+
+			posts, err = db.GetnPostsWithOffset(1, 0, viewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(posts, post.PostID) != visible {
+				t.Fatalf("%s post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+		}
+	}
+}
+
+func TestGetUserInboundOutboundVisibility(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	for _, c := range visibilityCases {
+		post := addVisiblePost(t, db, c.visibility)
+
+		for viewer, visible := range visibilityViewers(post, c) {
+			// The post is inbound to the recipient
+			posts, err := db.GetUserInboundOutbound(
+				string(post.Recipients[0]), viewer,
+			)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(posts[0], post.PostID) != visible {
+				t.Fatalf("%s inbound post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+
+			inbound, err := db.GetUserInbound(string(post.Recipients[0]), viewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(inbound, post.PostID) != visible {
+				t.Fatalf("%s inbound post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+
+			// And outbound from the sender
+			posts, err = db.GetUserInboundOutbound(string(post.Sender), viewer)
+			if err != nil {
+				t.Fatal(err)
+			}
+			if containsPost(posts[1], post.PostID) != visible {
+				t.Fatalf("%s outbound post visible to %s: %t", c.visibility, viewer, !visible)
+			}
+		}
+	}
+}
+
+// containsSuggestion checks if a slice of suggestions suggests a user.
+func containsSuggestion(
+	suggestions []models.UserSuggestion,
+	username models.Username,
+) bool {
+	for _, suggestion := range suggestions {
+		if suggestion.Username == username {
+			return true
+		}
+	}
+	return false
+}
+
+func TestSuggestUsers(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	post := addVisiblePost(t, db, models.Public)
+	recipient := string(post.Recipients[0])
+
+	// An exact match comes first
+	suggestions, err := db.SuggestUsers(recipient, 10, -1, "some.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) == 0 || suggestions[0].Username != post.Recipients[0] {
+		t.Fatalf("%s was not suggested first: %v", recipient, suggestions)
+	}
+
+	// A misspelling is still a close fuzzy match
+	typo := strings.Replace(recipient, "first", "frist", 1)
+	suggestions, err = db.SuggestUsers(typo, 10, -1, "some.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(suggestions) == 0 || suggestions[0].Username != post.Recipients[0] {
+		t.Fatalf("%s was not suggested first for %s: %v", recipient, typo, suggestions)
+	}
+
+	// Correspondents come before every other prefix match
+	suggestions, err = db.SuggestUsers("first", 1, -1, string(post.Sender))
 	if err != nil {
 		t.Fatal(err)
 	}
+	if len(suggestions) != 1 || suggestions[0].Username != post.Recipients[0] ||
+		!suggestions[0].Correspondent {
+		t.Fatalf("correspondent %s was not suggested first: %v", recipient, suggestions)
+	}
+
+	// Other grades and the user searching are left out
+	suggestions, err = db.SuggestUsers(recipient, 10, models.Freshman, "some.one")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsSuggestion(suggestions, post.Recipients[0]) {
+		t.Fatalf("senior %s was suggested among freshmen", recipient)
+	}
+	suggestions, err = db.SuggestUsers(recipient, 10, -1, recipient)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if containsSuggestion(suggestions, post.Recipients[0]) {
+		t.Fatalf("%s was suggested to themselves", recipient)
+	}
+}
+
+// addTestUser adds a new senior with a random username.
+func addTestUser(t *testing.T, db *Database) models.Username {
+	user, err := models.NewUser(genRandEmail(), models.Senior, false)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddUser(user)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return user.Username
+}
+
+// addPostBetween adds a live public post from one existing user to
+// another.
+func addPostBetween(
+	t *testing.T,
+	db *Database,
+	sender models.Username,
+	recipient models.Username,
+) *models.Post {
+	post, err := models.NewPost(
+		string(sender),
+		"I am a message",
+		[]string{},
+		[]string{string(recipient)},
+		common.DefaultLimits,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Status = models.Approved
+	post.Visibility = models.Public
+	post.LiveAt = time.Now()
+
+	err = db.AddPost(post)
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = db.AddToAndFrom(post.PostID, string(sender), []string{string(recipient)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	return post
+}
+
+func TestGetReciprocations(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	recipient := addTestUser(t, db)
+	waiting, hidden, blocked, answered :=
+		addTestUser(t, db), addTestUser(t, db), addTestUser(t, db), addTestUser(t, db)
+	addPostBetween(t, db, waiting, recipient)
+
+	// The recipient hides one post, blocks one sender and writes back to
+	// another
+	post := addPostBetween(t, db, hidden, recipient)
+	_, err := db.HidePost(recipient, post.PostID)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addPostBetween(t, db, blocked, recipient)
+	err = db.BlockUser(recipient, blocked)
+	if err != nil {
+		t.Fatal(err)
+	}
+	addPostBetween(t, db, answered, recipient)
+	addPostBetween(t, db, recipient, answered)
+
+	reciprocations, err := db.GetReciprocations(string(recipient))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(reciprocations) != 1 || reciprocations[0].Username != waiting {
+		t.Fatalf("expected only %s to be reciprocated, got %v", waiting, reciprocations)
+	}
+
+	// A user whose only post to reciprocate is hidden is not reminded
+	other := addTestUser(t, db)
+	post = addPostBetween(t, db, addTestUser(t, db), other)
+	_, err = db.HidePost(other, post.PostID)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	usernames, err := db.GetReciprocationUsers()
+	if err != nil {
+		t.Fatal(err)
+	}
+	reminded := map[models.Username]bool{}
+	for _, username := range usernames {
+		reminded[username] = true
+	}
+	if !reminded[recipient] || reminded[other] {
+		t.Fatalf("expected %s to be reminded and %s not to be", recipient, other)
+	}
+}
+
+// leaderboardPosts gets the posts counted for a user on a leaderboard, and
+// whether the user is on it.
+func leaderboardPosts(
+	entries []models.LeaderboardEntry,
+	username models.Username,
+) (int, bool) {
+	for _, entry := range entries {
+		if entry.Username == username {
+			return entry.Posts, true
+		}
+	}
+	return 0, false
+}
+
+// postsToday gets the number of posts that went live today.
+func postsToday(stats *models.Stats) int {
+	if len(stats.PostsByDay) == 0 {
+		return 0
+	}
+	return stats.PostsByDay[len(stats.PostsByDay)-1].Posts
+}
+
+func TestGetStats(t *testing.T) {
+	rand.Seed(time.Now().UnixNano())
+	db := connect(t)
+	defer db.Disconnect()
+
+	// Every user fits on the leaderboards
+	const top = 1000000
+	before, err := db.GetStats(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// A sender with a public and a private post, one written long before
+	// it went live
+	sender, recipient := addTestUser(t, db), addTestUser(t, db)
+	post := addPostBetween(t, db, sender, recipient)
+	_, err = db.DB.Model((*models.Post)(nil)).
+		Set("timestamp = ?", post.Timestamp.AddDate(0, 0, -10)).
+		Where("post_id = ?", post.PostID).
+		Update()
+	if err != nil {
+		t.Fatal(err)
+	}
+	private := addVisiblePost(t, db, models.Private)
+
+	// And a sender hidden from the leaderboards
+	hidden := addTestUser(t, db)
+	addPostBetween(t, db, hidden, addTestUser(t, db))
+	err = db.SetHiddenFromLeaderboard(string(hidden), true)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	stats, err := db.GetStats(top)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if stats.Posts != before.Posts+3 {
+		t.Fatalf("expected %d posts, got %d", before.Posts+3, stats.Posts)
+	}
+	if postsToday(stats) != postsToday(before)+3 {
+		t.Fatalf("expected %d posts today, got %d",
+			postsToday(before)+3, postsToday(stats))
+	}
+
+	if posts, ok := leaderboardPosts(stats.TopSenders, sender); !ok || posts != 1 {
+		t.Fatalf("expected %s to have sent 1 public post, got %d", sender, posts)
+	}
+	if posts, _ := leaderboardPosts(stats.TopRecipients, recipient); posts != 1 {
+		t.Fatalf("expected %s to have received 1 counted post, got %d", recipient, posts)
+	}
+	if _, ok := leaderboardPosts(stats.TopSenders, hidden); ok {
+		t.Fatalf("%s is hidden from the leaderboards", hidden)
+	}
+	if _, ok := leaderboardPosts(stats.TopSenders, private.Sender); ok {
+		t.Fatalf("%s only sent a private post", private.Sender)
+	}
+	if _, ok := leaderboardPosts(stats.TopRecipients, private.Recipients[0]); ok {
+		t.Fatalf("%s only received a private post", private.Recipients[0])
+	}
 }
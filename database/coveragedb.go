@@ -0,0 +1,67 @@
+package database
+
+import "github.com/mattnappo/yearbook/models"
+
+// GetCoverage gets how many live posts each senior has received and from
+// how many different senders, fewest first. Seniors with more posts or
+// more senders than the given maximums are left out. A negative maximum
+// is no maximum.
+func (db *Database) GetCoverage(
+	maxPosts, maxSenders int,
+) ([]models.Coverage, error) {
+	coverage := []models.Coverage{}
+	_, err := db.DB.Query(&coverage, `
+		SELECT u.username, u.firstname, u.lastname,
+			count(p.id) AS posts, count(DISTINCT p.sender) AS senders
+		FROM users AS u
+		LEFT JOIN posts AS p
+			ON p.status = ?0 AND p.recipients @> to_jsonb(u.username::text)
+		WHERE u.grade = ?1
+		GROUP BY u.username, u.firstname, u.lastname
+		HAVING (?2 < 0 OR count(p.id) <= ?2)
+			AND (?3 < 0 OR count(DISTINCT p.sender) <= ?3)
+		ORDER BY posts ASC, senders ASC, u.username ASC`,
+		models.Approved, models.Senior, maxPosts, maxSenders,
+	)
+	return coverage, err
+}
+
+// SuggestSomeoneNew gets the seniors who are open to suggestions with the
+// fewest live posts, leaving out the user asking, seniors they have
+// already written to and seniors who blocked them.
+func (db *Database) SuggestSomeoneNew(
+	username string,
+	limit int,
+) ([]models.UserSuggestion, error) {
+	suggestions := []models.UserSuggestion{}
+	_, err := db.DB.Query(&suggestions, `
+		SELECT u.username, u.firstname, u.lastname, u.nickname, u.grade,
+			u.profile_pic
+		FROM users AS u
+		LEFT JOIN posts AS p
+			ON p.status = ?0 AND p.recipients @> to_jsonb(u.username::text)
+		WHERE u.grade = ?1 AND u.open_to_suggestions AND u.username != ?2
+			AND NOT coalesce(u.blocked @> to_jsonb(?2::text), false)
+			AND NOT EXISTS (
+				SELECT 1 FROM posts AS o
+				WHERE o.sender = ?2
+				AND o.recipients @> to_jsonb(u.username::text)
+			)
+		GROUP BY u.username, u.firstname, u.lastname, u.nickname, u.grade,
+			u.profile_pic
+		ORDER BY count(p.id) ASC, random()
+		LIMIT ?3`,
+		models.Approved, models.Senior, username, limit,
+	)
+	return suggestions, err
+}
+
+// SetOpenToSuggestions turns a user's "open to suggestions" setting on or
+// off.
+func (db *Database) SetOpenToSuggestions(username string, enabled bool) error {
+	_, err := db.DB.Model((*models.User)(nil)).
+		Set("open_to_suggestions = ?", enabled).
+		Where("username = ?", username).
+		Update()
+	return err
+}
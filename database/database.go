@@ -8,6 +8,7 @@ import (
 	"sync"
 
 	"github.com/go-pg/pg/v9"
+	"github.com/go-pg/pg/v9/orm"
 	"github.com/mattnappo/yearbook/common"
 	"github.com/mattnappo/yearbook/models"
 )
@@ -36,14 +37,26 @@ type Database struct {
 	mux    sync.Mutex
 }
 
-// Connect connects to the database.
-func Connect(fromStdin bool) *Database {
+// Connect connects to the database. It fails if the config is missing
+// any keys.
+func Connect(config common.DatabaseConfig) (*Database, error) {
+	err := config.Validate()
+	if err != nil {
+		return nil, err
+	}
+
 	db := pg.Connect(&pg.Options{
-		User:     "postgres",
-		Password: common.GetEnv("DB_PASSWORD"),
-		Database: common.DatabaseName,
+		Addr:     config.Addr,
+		User:     config.User,
+		Password: config.Password,
+		Database: config.Name,
 	})
-	return &Database{db, CONNECTED, sync.Mutex{}}
+	return &Database{db, CONNECTED, sync.Mutex{}}, nil
+}
+
+// PoolStats returns the stats of the connection pool.
+func (db *Database) PoolStats() *pg.PoolStats {
+	return db.DB.PoolStats()
 }
 
 // Disconnect disconnects from the database.
@@ -57,20 +70,99 @@ func (db *Database) Disconnect() error {
 	return nil
 }
 
+// schemaModels are the models that have a table in the database.
+var schemaModels = []interface{}{
+	(*models.User)(nil),                    // Make the users table
+	(*models.Post)(nil),                    // make the posts table
+	(*token)(nil),                          // make the tokens table
+	(*models.FilterWord)(nil),              // make the content filter table
+	(*models.Report)(nil),                  // make the reports table
+	(*models.Notification)(nil),            // make the notification outbox table
+	(*models.NotificationPreferences)(nil), // make the preferences table
+	(*models.InAppNotification)(nil),       // make the in-app notifications table
+	(*models.Reaction)(nil),                // make the reactions table
+	(*models.Comment)(nil),                 // make the comments table
+	(*models.UsageEvent)(nil),              // make the usage events table
+	(*models.DailyUsage)(nil),              // make the daily usage table
+}
+
 // CreateSchema creates the database schema.
 func (db *Database) CreateSchema() error {
 	db.mux.Lock()
 	defer db.mux.Unlock()
-	for _, model := range []interface{}{
-		(*models.User)(nil), // Make the users table
-		(*models.Post)(nil), // make the posts table
-		(*token)(nil)} {     // make the tokens table
+	for _, model := range schemaModels {
 		err := db.DB.CreateTable(model, nil)
 		if err != nil {
 			return err
 		}
 	}
-	return nil
+	err := db.createSearchIndexes()
+	if err != nil {
+		return err
+	}
+	return db.seedFilterWords()
+}
+
+// migrations add the columns that the users and posts tables did not
+// have when they were first created. Posts that were already there were
+// published before posts could be held, so they are made public and live
+// as of when they were posted. Every step can be run more than once.
+var migrations = []string{
+	`ALTER TABLE users
+		ADD COLUMN IF NOT EXISTS role bigint NOT NULL DEFAULT 0,
+		ADD COLUMN IF NOT EXISTS do_not_accept_posts boolean,
+		ADD COLUMN IF NOT EXISTS open_to_suggestions boolean,
+		ADD COLUMN IF NOT EXISTS hidden_from_leaderboard boolean,
+		ADD COLUMN IF NOT EXISTS blocked jsonb,
+		ADD COLUMN IF NOT EXISTS hidden_posts jsonb`,
+	`ALTER TABLE posts
+		ADD COLUMN IF NOT EXISTS status bigint,
+		ADD COLUMN IF NOT EXISTS flagged boolean,
+		ADD COLUMN IF NOT EXISTS moderated_by text,
+		ADD COLUMN IF NOT EXISTS moderation_reason text,
+		ADD COLUMN IF NOT EXISTS visibility text,
+		ADD COLUMN IF NOT EXISTS mentions jsonb,
+		ADD COLUMN IF NOT EXISTS publish_at timestamptz,
+		ADD COLUMN IF NOT EXISTS live_at timestamptz,
+		ADD COLUMN IF NOT EXISTS reaction_notified boolean`,
+	`UPDATE posts SET status = ?0, live_at = timestamp WHERE status IS NULL`,
+	`UPDATE posts SET visibility = ?1 WHERE visibility IS NULL`,
+	`ALTER TABLE posts ALTER COLUMN visibility SET NOT NULL`,
+}
+
+// MigrateSchema brings the schema of a database created by an older
+// version up to date. Missing tables are created, and the columns that
+// were added to existing tables are added and filled in.
+func (db *Database) MigrateSchema() error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	for _, model := range schemaModels {
+		err := db.DB.CreateTable(model, &orm.CreateTableOptions{
+			IfNotExists: true,
+		})
+		if err != nil {
+			return err
+		}
+	}
+
+	err := db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		for _, migration := range migrations {
+			_, err := tx.Exec(migration, models.Approved, models.Public)
+			if err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	err = db.createSearchIndexes()
+	if err != nil {
+		return err
+	}
+	return db.seedFilterWords()
 }
 
 // AddSeniors adds all the seinors into the database.
@@ -0,0 +1,89 @@
+package database
+
+import (
+	"fmt"
+	"strings"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+// searchColumns are the columns of the users table searched when
+// suggesting users. Each has a trigram index on its lowercase value, which
+// serves both the prefix and the fuzzy matches.
+var searchColumns = []string{"username", "firstname", "lastname", "nickname"}
+
+// likeEscaper escapes the LIKE wildcards in a search query.
+var likeEscaper = strings.NewReplacer(`\`, `\\`, `%`, `\%`, `_`, `\_`)
+
+// createSearchIndexes creates the trigram indexes used to search the user
+// directory.
+func (db *Database) createSearchIndexes() error {
+	_, err := db.DB.Exec("CREATE EXTENSION IF NOT EXISTS pg_trgm")
+	if err != nil {
+		return err
+	}
+	for _, column := range searchColumns {
+		_, err = db.DB.Exec(fmt.Sprintf(
+			"CREATE INDEX IF NOT EXISTS users_%s_trgm_idx "+
+				"ON users USING gin (lower(%s) gin_trgm_ops)",
+			column, column,
+		))
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// SuggestUsers searches the user directory for users whose names or
+// username start with or are similar to the query. If grade is not
+// negative, only users in that grade are suggested. Users who have
+// exchanged live posts with the user searching come first, then prefix
+// matches, then the closest fuzzy matches.
+func (db *Database) SuggestUsers(
+	query string,
+	limit int,
+	grade int,
+	searcher string,
+) ([]models.UserSuggestion, error) {
+	suggestions := []models.UserSuggestion{}
+	query = strings.ToLower(strings.TrimSpace(query))
+	if query == "" {
+		return suggestions, nil
+	}
+
+	// Build the conditions over every searched column
+	var prefix, fuzzy, similarity []string
+	for _, column := range searchColumns {
+		prefix = append(prefix, fmt.Sprintf("lower(u.%s) LIKE ?0", column))
+		fuzzy = append(fuzzy, fmt.Sprintf("lower(u.%s) %% ?1", column))
+		similarity = append(similarity,
+			fmt.Sprintf("coalesce(similarity(lower(u.%s), ?1), 0)", column))
+	}
+	prefixMatch := "(" + strings.Join(prefix, " OR ") + ")"
+
+	_, err := db.DB.Query(&suggestions, `
+		WITH correspondents AS (
+			SELECT sender AS username FROM posts
+			WHERE status = ?2 AND recipients @> to_jsonb(?3::text)
+			UNION
+			SELECT jsonb_array_elements_text(recipients) FROM posts
+			WHERE status = ?2 AND sender = ?3
+		)
+		SELECT u.username, u.firstname, u.lastname, u.nickname, u.grade,
+			u.profile_pic,
+			u.username IN (SELECT username FROM correspondents)
+				AS correspondent
+		FROM users AS u
+		WHERE (`+prefixMatch+` OR `+strings.Join(fuzzy, " OR ")+`)
+			AND (?4 < 0 OR u.grade = ?4)
+			AND u.username != ?3
+		ORDER BY correspondent DESC, `+prefixMatch+` DESC,
+			greatest(`+strings.Join(similarity, ", ")+`) DESC,
+			u.username ASC
+		LIMIT ?5`,
+		likeEscaper.Replace(query)+"%", query, models.Approved,
+		searcher, grade, limit,
+	)
+	return suggestions, err
+}
@@ -0,0 +1,104 @@
+package database
+
+import (
+	"errors"
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errNotDraft is returned when changing a post that is not a draft or a
+// scheduled post.
+var errNotDraft = errors.New("post is not a draft or scheduled post")
+
+// unpublished is the statuses of posts that have not been published.
+var unpublished = []models.Status{models.Draft, models.Scheduled}
+
+// GetDrafts gets the drafts and scheduled posts of a user, oldest first.
+func (db *Database) GetDrafts(sender string) ([]models.Post, error) {
+	posts := []models.Post{}
+	err := db.DB.Model(&posts).
+		Where("sender = ?", sender).
+		WhereIn("status IN (?)", unpublished).
+		Order("id ASC").
+		Select()
+	return posts, err
+}
+
+// GetDuePosts gets the scheduled posts that are due to be published.
+func (db *Database) GetDuePosts(now time.Time) ([]models.Post, error) {
+	var posts []models.Post
+	err := db.DB.Model(&posts).
+		Where("status = ?", models.Scheduled).
+		Where("publish_at <= ?", now).
+		Order("publish_at ASC").
+		Select()
+	return posts, err
+}
+
+// UpdateDraft replaces the contents of a draft or scheduled post.
+func (db *Database) UpdateDraft(post *models.Post) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	res, err := db.DB.Model(post).
+		Column("recipients", "message", "images", "visibility", "mentions").
+		Column("flagged", "status", "publish_at").
+		WherePK().
+		WhereIn("status IN (?)", unpublished).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return errNotDraft
+	}
+	return nil
+}
+
+// PublishDraft publishes a draft or scheduled post with the status it
+// was given, and adds the notifications about it in the same transaction.
+// It fails if the post was already published.
+func (db *Database) PublishDraft(
+	post *models.Post,
+	notifs ...*models.Notification,
+) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		res, err := tx.Model(post).
+			Column("recipients", "message", "images", "visibility", "mentions").
+			Column("flagged", "status", "timestamp", "live_at").
+			WherePK().
+			WhereIn("status IN (?)", unpublished).
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errNotDraft
+		}
+		return enqueue(tx, notifs)
+	})
+}
+
+// DeleteDraft deletes a draft or scheduled post of a user.
+func (db *Database) DeleteDraft(postID string, sender string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	res, err := db.DB.Model((*models.Post)(nil)).
+		Where("post_id = ?", postID).
+		Where("sender = ?", sender).
+		WhereIn("status IN (?)", unpublished).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return errNotDraft
+	}
+	return nil
+}
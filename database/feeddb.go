@@ -0,0 +1,58 @@
+package database
+
+import (
+	"github.com/mattnappo/yearbook/models"
+)
+
+// AddInAppNotifications adds notifications to users' in-app feeds.
+func (db *Database) AddInAppNotifications(notifs ...*models.InAppNotification) error {
+	if len(notifs) == 0 {
+		return nil
+	}
+	_, err := db.DB.Model(&notifs).Insert()
+	return err
+}
+
+// GetInAppNotifications gets n of a user's in-app notifications at a
+// certain offset, newest first.
+func (db *Database) GetInAppNotifications(
+	username string,
+	n, offset int,
+) ([]models.InAppNotification, error) {
+	var notifs []models.InAppNotification
+	err := db.DB.Model(&notifs).
+		Where("username = ?", username).
+		Order("id DESC").
+		Limit(n).
+		Offset(offset).
+		Select()
+	return notifs, err
+}
+
+// GetUnreadCount gets the number of unread in-app notifications of a user.
+func (db *Database) GetUnreadCount(username string) (int, error) {
+	return db.DB.Model((*models.InAppNotification)(nil)).
+		Where("username = ?", username).
+		Where("read = false").
+		Count()
+}
+
+// MarkNotificationRead marks one of a user's in-app notifications read.
+func (db *Database) MarkNotificationRead(username string, id int64) error {
+	_, err := db.DB.Model((*models.InAppNotification)(nil)).
+		Set("read = true").
+		Where("id = ?", id).
+		Where("username = ?", username).
+		Update()
+	return err
+}
+
+// MarkAllNotificationsRead marks all of a user's in-app notifications read.
+func (db *Database) MarkAllNotificationsRead(username string) error {
+	_, err := db.DB.Model((*models.InAppNotification)(nil)).
+		Set("read = true").
+		Where("username = ?", username).
+		Where("read = false").
+		Update()
+	return err
+}
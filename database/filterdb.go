@@ -0,0 +1,54 @@
+package database
+
+import (
+	"time"
+
+	"github.com/mattnappo/yearbook/filter"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// GetFilterWords gets the deny and allow lists of the content filter.
+func (db *Database) GetFilterWords() ([]models.FilterWord, error) {
+	var words []models.FilterWord
+	err := db.DB.Model(&words).Order("word ASC").Select()
+	return words, err
+}
+
+// AddFilterWord adds a word to a content filter list, moving it if it is
+// already on the other list.
+func (db *Database) AddFilterWord(word *models.FilterWord) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	_, err := db.DB.Model(word).
+		OnConflict("(word) DO UPDATE").
+		Set("list = EXCLUDED.list").
+		Set("added_by = EXCLUDED.added_by").
+		Set("timestamp = EXCLUDED.timestamp").
+		Insert()
+	return err
+}
+
+// DeleteFilterWord deletes a word from the content filter lists.
+func (db *Database) DeleteFilterWord(word string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	_, err := db.DB.Model((*models.FilterWord)(nil)).
+		Where("word = ?", word).
+		Delete()
+	return err
+}
+
+// seedFilterWords fills the deny list with the default deny list.
+func (db *Database) seedFilterWords() error {
+	for _, word := range filter.DefaultDenyList {
+		_, err := db.DB.Model(&models.FilterWord{
+			Word:      word,
+			List:      models.DenyList,
+			Timestamp: time.Now(),
+		}).OnConflict("DO NOTHING").Insert()
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
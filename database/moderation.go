@@ -0,0 +1,83 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errNotPending is returned when moderating a post that is not pending.
+var errNotPending = errors.New("post is not pending moderation")
+
+// GetModerationQueue gets all of the posts that are pending moderation,
+// oldest first.
+func (db *Database) GetModerationQueue() ([]models.Post, error) {
+	var posts []models.Post
+	err := db.DB.Model(&posts).
+		Where("status = ?", models.Pending).
+		Order("id ASC").
+		Select()
+	return posts, err
+}
+
+// ModeratePost sets the moderation status of a pending post, and adds the
+// notifications about the decision in the same transaction. An approved
+// post keeps the time it first went live, if it was live before, and its
+// open reports are dismissed so that it is not hidden again by the next
+// report.
+func (db *Database) ModeratePost(
+	postID string,
+	status models.Status,
+	moderator models.Username,
+	reason string,
+	notifs ...*models.Notification,
+) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		q := tx.Model((*models.Post)(nil))
+		if status == models.Approved {
+			q = q.Set("live_at = COALESCE(live_at, now())")
+		}
+		res, err := q.
+			Set("status = ?", status).
+			Set("moderated_by = ?", moderator).
+			Set("moderation_reason = ?", reason).
+			Where("post_id = ?", postID).
+			Where("status = ?", models.Pending).
+			Update()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errNotPending
+		}
+
+		if status == models.Approved {
+			_, err = tx.Model((*models.Report)(nil)).
+				Set("status = ?", models.Dismissed).
+				Set("reviewed_by = ?", moderator).
+				Set("review_note = ?", "post approved").
+				Where("post_id = ?", postID).
+				Where("status = ?", models.Open).
+				Update()
+			if err != nil {
+				return err
+			}
+		}
+		return enqueue(tx, notifs)
+	})
+}
+
+// GetUserRole gets a user's role given a username.
+func (db *Database) GetUserRole(username string) (models.Role, error) {
+	var role models.Role
+	err := db.DB.Model((*models.User)(nil)).
+		Column("role").
+		Where("username = ?", username).
+		Select(&role)
+
+	return role, err
+}
@@ -0,0 +1,134 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// EnqueueNotification adds notifications to the outbox.
+func (db *Database) EnqueueNotification(notifs ...*models.Notification) error {
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		return enqueue(tx, notifs)
+	})
+}
+
+// enqueue adds notifications to the outbox within a transaction.
+func enqueue(tx *pg.Tx, notifs []*models.Notification) error {
+	for _, notif := range notifs {
+		err := tx.Insert(notif)
+		if err != nil {
+			return err
+		}
+	}
+	return nil
+}
+
+// ClaimNotifications marks up to limit notifications that are due as being
+// sent, and returns them.
+func (db *Database) ClaimNotifications(limit int) ([]models.Notification, error) {
+	var notifs []models.Notification
+	err := db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		err := tx.Model(&notifs).
+			WhereIn("status IN (?)", []models.NotificationStatus{
+				models.Queued, models.Failed,
+			}).
+			Where("next_attempt <= ?", time.Now()).
+			Order("id ASC").
+			Limit(limit).
+			For("UPDATE SKIP LOCKED").
+			Select()
+		if err != nil || len(notifs) == 0 {
+			return err
+		}
+
+		var ids []int64
+		for _, notif := range notifs {
+			ids = append(ids, notif.ID)
+		}
+		_, err = tx.Model((*models.Notification)(nil)).
+			Set("status = ?", models.Sending).
+			WhereIn("id IN (?)", ids).
+			Update()
+		return err
+	})
+	return notifs, err
+}
+
+// MarkNotificationSent marks a notification as delivered.
+func (db *Database) MarkNotificationSent(id int64) error {
+	_, err := db.DB.Model((*models.Notification)(nil)).
+		Set("status = ?", models.Sent).
+		Set("sent_at = ?", time.Now()).
+		Set("attempts = attempts + 1").
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+// MarkNotificationFailed records a failed delivery of a notification. If
+// dead is true, it will not be retried.
+func (db *Database) MarkNotificationFailed(
+	id int64,
+	sendErr error,
+	nextAttempt time.Time,
+	dead bool,
+) error {
+	status := models.NotificationStatus(models.Failed)
+	if dead {
+		status = models.Dead
+	}
+	_, err := db.DB.Model((*models.Notification)(nil)).
+		Set("status = ?", status).
+		Set("attempts = attempts + 1").
+		Set("next_attempt = ?", nextAttempt).
+		Set("last_error = ?", sendErr.Error()).
+		Where("id = ?", id).
+		Update()
+	return err
+}
+
+// ResetSendingNotifications puts the notifications that were being sent
+// when the server stopped back in the outbox.
+func (db *Database) ResetSendingNotifications() error {
+	_, err := db.DB.Model((*models.Notification)(nil)).
+		Set("status = ?", models.Queued).
+		Where("status = ?", models.Sending).
+		Update()
+	return err
+}
+
+// GetFailedNotifications gets the notifications that failed to send,
+// newest first.
+func (db *Database) GetFailedNotifications() ([]models.Notification, error) {
+	var notifs []models.Notification
+	err := db.DB.Model(&notifs).
+		WhereIn("status IN (?)", []models.NotificationStatus{
+			models.Failed, models.Dead,
+		}).
+		Order("id DESC").
+		Select()
+	return notifs, err
+}
+
+// RetryNotification puts a failed notification back in the outbox to be
+// sent right away.
+func (db *Database) RetryNotification(id int64) error {
+	res, err := db.DB.Model((*models.Notification)(nil)).
+		Set("status = ?", models.Queued).
+		Set("attempts = 0").
+		Set("next_attempt = ?", time.Now()).
+		Where("id = ?", id).
+		WhereIn("status IN (?)", []models.NotificationStatus{
+			models.Failed, models.Dead,
+		}).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
@@ -0,0 +1,75 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// GetPreferences gets a user's notification preferences, or the default
+// preferences if they have never changed them.
+func (db *Database) GetPreferences(username string) (*models.NotificationPreferences, error) {
+	prefs := &models.NotificationPreferences{}
+	err := db.DB.Model(prefs).
+		Where("username = ?", username).
+		Select()
+	if err == pg.ErrNoRows {
+		return models.DefaultPreferences(models.Username(username)), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if prefs.Cadences == nil {
+		prefs.Cadences = make(map[string]models.Cadence)
+	}
+	return prefs, nil
+}
+
+// SetPreferences inserts or updates a user's notification preferences.
+func (db *Database) SetPreferences(prefs *models.NotificationPreferences) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	_, err := db.DB.Model(prefs).
+		OnConflict("(username) DO UPDATE").
+		Set("email_enabled = EXCLUDED.email_enabled").
+		Set("cadences = EXCLUDED.cadences").
+		Insert()
+	return err
+}
+
+// GetDigestSubscribers gets the preferences of every user who wants a daily
+// digest of an event and has not had one since the given time.
+func (db *Database) GetDigestSubscribers(
+	event string,
+	since time.Time,
+) ([]models.NotificationPreferences, error) {
+	var prefs []models.NotificationPreferences
+	err := db.DB.Model(&prefs).
+		Where("email_enabled = true").
+		Where("cadences ->> ? = ?", event, models.Daily).
+		Where("(last_digest IS NULL OR last_digest < ?)", since).
+		Select()
+	return prefs, err
+}
+
+// RecordDigest moves a user's digest watermark forward, and adds the
+// digest email to the outbox in the same transaction so that no post is
+// sent twice.
+func (db *Database) RecordDigest(
+	username models.Username,
+	watermark time.Time,
+	notifs ...*models.Notification,
+) error {
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model((*models.NotificationPreferences)(nil)).
+			Set("last_digest = ?", watermark).
+			Where("username = ?", username).
+			Update()
+		if err != nil {
+			return err
+		}
+		return enqueue(tx, notifs)
+	})
+}
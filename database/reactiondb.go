@@ -0,0 +1,115 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// errDuplicateReaction is returned when a user reacts to a post with the
+// same emoji twice.
+var errDuplicateReaction = errors.New("already reacted with this emoji")
+
+// AddReaction adds a reaction to a post. The returned bool is true if it is
+// the first reaction to the post by someone other than its sender, which
+// the sender is notified of only once, even if it is removed and added
+// again.
+func (db *Database) AddReaction(reaction *models.Reaction) (bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	first := false
+	err := db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		res, err := tx.Model(reaction).
+			OnConflict("DO NOTHING").
+			Insert()
+		if err != nil {
+			return err
+		}
+		if res.RowsAffected() == 0 {
+			return errDuplicateReaction
+		}
+
+		res, err = tx.Model((*models.Post)(nil)).
+			Set("reaction_notified = true").
+			Where("post_id = ?", reaction.PostID).
+			Where("sender != ?", reaction.Username).
+			Where("reaction_notified IS NOT TRUE").
+			Update()
+		if err != nil {
+			return err
+		}
+		first = res.RowsAffected() == 1
+		return nil
+	})
+	return first, err
+}
+
+// RemoveReaction removes a user's reaction to a post.
+func (db *Database) RemoveReaction(
+	postID string,
+	username models.Username,
+	emoji string,
+) error {
+	_, err := db.DB.Model((*models.Reaction)(nil)).
+		Where("post_id = ?", postID).
+		Where("username = ?", username).
+		Where("emoji = ?", emoji).
+		Delete()
+	return err
+}
+
+// AttachReactions fills in the reaction counts of each post, and the
+// reactions of the user viewing them.
+func (db *Database) AttachReactions(posts []models.Post, viewer string) error {
+	if len(posts) == 0 {
+		return nil
+	}
+
+	var postIDs []string
+	for _, post := range posts {
+		postIDs = append(postIDs, post.PostID)
+	}
+
+	// Count the reactions to every post by emoji
+	var counts []struct {
+		PostID string
+		Emoji  string
+		Count  int
+	}
+	err := db.DB.Model((*models.Reaction)(nil)).
+		Column("post_id", "emoji").
+		ColumnExpr("count(*) AS count").
+		WhereIn("post_id IN (?)", postIDs).
+		Group("post_id", "emoji").
+		Select(&counts)
+	if err != nil {
+		return err
+	}
+
+	// Get the viewer's own reactions
+	var mine []models.Reaction
+	err = db.DB.Model(&mine).
+		WhereIn("post_id IN (?)", postIDs).
+		Where("username = ?", viewer).
+		Select()
+	if err != nil {
+		return err
+	}
+
+	index := make(map[string]int)
+	for i := range posts {
+		posts[i].Reactions = make(map[string]int)
+		posts[i].MyReactions = []string{}
+		index[posts[i].PostID] = i
+	}
+	for _, count := range counts {
+		posts[index[count.PostID]].Reactions[count.Emoji] = count.Count
+	}
+	for _, reaction := range mine {
+		i := index[reaction.PostID]
+		posts[i].MyReactions = append(posts[i].MyReactions, reaction.Emoji)
+	}
+	return nil
+}
@@ -0,0 +1,93 @@
+package database
+
+import (
+	"github.com/mattnappo/yearbook/models"
+)
+
+// HidePost hides a post from a recipient's profile and activity. The post
+// is moved from the recipient's inbound posts to their hidden posts. The
+// returned bool is false if there was nothing to hide, since the post was
+// already hidden or is not one of the recipient's inbound posts.
+func (db *Database) HidePost(username models.Username, postID string) (bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// Get the inbound and hidden posts
+	var user models.User
+	err := db.DB.Model((*models.User)(nil)).
+		Column("inbound_posts", "hidden_posts").
+		Where("username = ?", string(username)).
+		Select(&user)
+	if err != nil {
+		return false, err
+	}
+	if !hasPostID(user.InboundPosts, postID) ||
+		hasPostID(user.HiddenPosts, postID) {
+		return false, nil
+	}
+
+	err = db.removeInboundPost(username, postID)
+	if err != nil {
+		return false, err
+	}
+
+	// Update the array in the database with the newly hidden post
+	_, err = db.DB.Model((*models.User)(nil)).
+		Set("hidden_posts = ?", append(user.HiddenPosts, postID)).
+		Where("username = ?", string(username)).
+		Update()
+	return err == nil, err
+}
+
+// hasPostID checks if a list of post IDs has a post ID.
+func hasPostID(postIDs []string, postID string) bool {
+	for _, id := range postIDs {
+		if id == postID {
+			return true
+		}
+	}
+	return false
+}
+
+// GetUserHidden returns the posts that a user has hidden.
+func (db *Database) GetUserHidden(username string) ([]models.Post, error) {
+	var hiddenPostIDs models.User
+	err := db.DB.Model((*models.User)(nil)).
+		Column("hidden_posts").
+		Where("username = ?", username).
+		Select(&hiddenPostIDs)
+	if err != nil {
+		return nil, err
+	}
+
+	return db.traversePosts(hiddenPostIDs.HiddenPosts), nil
+}
+
+// RemoveRecipient removes a user from the recipients of a post and removes
+// the post from the user's inbound posts.
+func (db *Database) RemoveRecipient(username models.Username, postID string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	post, err := db.getPost(postID)
+	if err != nil {
+		return err
+	}
+
+	// Remove the user from the recipients
+	recipients := []models.Username{}
+	for _, recipient := range post.Recipients {
+		if recipient != username {
+			recipients = append(recipients, recipient)
+		}
+	}
+	_, err = db.DB.Model((*models.Post)(nil)).
+		Set("recipients = ?", recipients).
+		Where("post_id = ?", postID).
+		Update()
+	if err != nil {
+		return err
+	}
+
+	return db.removeInboundPost(username, postID)
+}
@@ -0,0 +1,82 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// notReciprocated is the condition for a live post p to a recipient r
+// that r has not sent a post back to the sender of. Posts waiting for a
+// moderator or scheduled for later count as sent back. Posts that r hid,
+// or whose sender r blocked, are left out.
+const notReciprocated = `p.status = ?0 AND p.sender != r.username
+	AND NOT EXISTS (
+		SELECT 1 FROM posts AS o
+		WHERE o.sender = r.username
+		AND o.recipients @> to_jsonb(p.sender::text)
+		AND o.status IN (?1, ?0, ?2)
+	)
+	AND NOT EXISTS (
+		SELECT 1 FROM users AS b
+		WHERE b.username = r.username
+		AND (coalesce(b.blocked @> to_jsonb(p.sender::text), false)
+			OR coalesce(b.hidden_posts @> to_jsonb(p.post_id::text), false))
+	)`
+
+// GetReciprocations gets the people who sent live posts to a user that
+// the user has not sent a post back to, most recent first.
+func (db *Database) GetReciprocations(
+	username string,
+) ([]models.Reciprocation, error) {
+	reciprocations := []models.Reciprocation{}
+	_, err := db.DB.Query(&reciprocations, `
+		SELECT p.sender AS username, u.firstname, u.lastname, u.profile_pic,
+			count(*) AS posts, max(p.timestamp) AS last_post_at
+		FROM posts AS p
+		CROSS JOIN (SELECT ?3::text AS username) AS r
+		LEFT JOIN users AS u ON u.username = p.sender
+		WHERE p.recipients @> to_jsonb(r.username) AND `+notReciprocated+`
+		GROUP BY p.sender, u.firstname, u.lastname, u.profile_pic
+		ORDER BY last_post_at DESC`,
+		models.Approved, models.Pending, models.Scheduled, username,
+	)
+	return reciprocations, err
+}
+
+// GetReciprocationUsers gets the users who have at least one person to
+// congratulate back.
+func (db *Database) GetReciprocationUsers() ([]models.Username, error) {
+	var usernames []models.Username
+	_, err := db.DB.Query(&usernames, `
+		SELECT DISTINCT r.username
+		FROM posts AS p
+		CROSS JOIN jsonb_array_elements_text(p.recipients) AS r(username)
+		WHERE `+notReciprocated,
+		models.Approved, models.Pending, models.Scheduled,
+	)
+	return usernames, err
+}
+
+// RecordReminder moves a user's reminder watermark forward, and adds the
+// reminder email to the outbox in the same transaction. Users who have
+// never changed their preferences are given the default preferences.
+func (db *Database) RecordReminder(
+	username models.Username,
+	watermark time.Time,
+	notifs ...*models.Notification,
+) error {
+	prefs := models.DefaultPreferences(username)
+	prefs.LastReminder = watermark
+	return db.DB.RunInTransaction(func(tx *pg.Tx) error {
+		_, err := tx.Model(prefs).
+			OnConflict("(username) DO UPDATE").
+			Set("last_reminder = EXCLUDED.last_reminder").
+			Insert()
+		if err != nil {
+			return err
+		}
+		return enqueue(tx, notifs)
+	})
+}
@@ -0,0 +1,122 @@
+package database
+
+import (
+	"errors"
+
+	"github.com/mattnappo/yearbook/models"
+)
+
+var (
+	// errDuplicateReport is returned when a user reports a post twice.
+	errDuplicateReport = errors.New("post has already been reported by this user")
+
+	// errNotOpen is returned when reviewing a report that is not open.
+	errNotOpen = errors.New("report is not open")
+)
+
+// AddReport adds a report to the database. If the post now has at least
+// threshold open reports, it is hidden until a moderator reviews it. The
+// returned bool is true if the post was hidden by this report.
+func (db *Database) AddReport(report *models.Report, threshold int) (bool, error) {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	// Only one report per user per post
+	res, err := db.DB.Model(report).
+		OnConflict("DO NOTHING").
+		Insert()
+	if err != nil {
+		return false, err
+	}
+	if res.RowsAffected() == 0 {
+		return false, errDuplicateReport
+	}
+
+	// Count the open reports of the post
+	open, err := db.DB.Model((*models.Report)(nil)).
+		Where("post_id = ?", report.PostID).
+		Where("status = ?", models.Open).
+		Count()
+	if err != nil || open < threshold {
+		return false, err
+	}
+
+	// Hide the post by sending it back to the moderation queue
+	res, err = db.DB.Model((*models.Post)(nil)).
+		Set("status = ?", models.Pending).
+		Set("moderation_reason = ?", "hidden after reports").
+		Where("post_id = ?", report.PostID).
+		Where("status = ?", models.Approved).
+		Update()
+	if err != nil {
+		return false, err
+	}
+	return res.RowsAffected() > 0, nil
+}
+
+// GetReports gets all of the reports with a given status, oldest first.
+func (db *Database) GetReports(status models.ReportStatus) ([]models.Report, error) {
+	var reports []models.Report
+	err := db.DB.Model(&reports).
+		Where("status = ?", status).
+		Order("id ASC").
+		Select()
+	return reports, err
+}
+
+// GetReport gets a report from the database.
+func (db *Database) GetReport(id int) (models.Report, error) {
+	report := &models.Report{}
+	err := db.DB.Model(report).
+		Where("id = ?", id).
+		Select()
+	if err != nil {
+		return models.Report{}, err
+	}
+
+	return *report, nil
+}
+
+// ReviewReport resolves or dismisses an open report.
+func (db *Database) ReviewReport(
+	id int,
+	status models.ReportStatus,
+	moderator models.Username,
+	note string,
+) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	res, err := db.DB.Model((*models.Report)(nil)).
+		Set("status = ?", status).
+		Set("reviewed_by = ?", moderator).
+		Set("review_note = ?", note).
+		Where("id = ?", id).
+		Where("status = ?", models.Open).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return errNotOpen
+	}
+	return nil
+}
+
+// RemovePost rejects a post no matter what its current status is.
+func (db *Database) RemovePost(
+	postID string,
+	moderator models.Username,
+	reason string,
+) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+
+	_, err := db.DB.Model((*models.Post)(nil)).
+		Set("status = ?", models.Rejected).
+		Set("moderated_by = ?", moderator).
+		Set("moderation_reason = ?", reason).
+		Where("post_id = ?", postID).
+		Update()
+	return err
+}
@@ -0,0 +1,127 @@
+package database
+
+import (
+	"time"
+
+	"github.com/go-pg/pg/v9"
+	"github.com/mattnappo/yearbook/models"
+)
+
+// GetStats computes the statistics of the whole class. Every live post
+// counts towards the totals, but the leaderboards only count public posts
+// and leave out users hidden from them. Each leaderboard has at most top
+// users.
+func (db *Database) GetStats(top int) (*models.Stats, error) {
+	stats := &models.Stats{
+		PostsByDay:    []models.DayCount{},
+		TopSenders:    []models.LeaderboardEntry{},
+		TopRecipients: []models.LeaderboardEntry{},
+		ComputedAt:    time.Now(),
+	}
+
+	// Post totals
+	var totals struct {
+		Posts         int
+		Images        int
+		AvgRecipients float64
+	}
+	_, err := db.DB.QueryOne(&totals, `
+		SELECT count(*) AS posts,
+			coalesce(sum(coalesce(array_length(images, 1), 0)), 0) AS images,
+			coalesce(avg(jsonb_array_length(recipients)), 0) AS avg_recipients
+		FROM posts
+		WHERE status = ?`,
+		models.Approved,
+	)
+	if err != nil {
+		return nil, err
+	}
+	stats.Posts = totals.Posts
+	stats.Images = totals.Images
+	stats.AvgRecipients = totals.AvgRecipients
+
+	// Posts over time, by the day they went live
+	_, err = db.DB.Query(&stats.PostsByDay, `
+		SELECT date_trunc('day', coalesce(live_at, timestamp)) AS day,
+			count(*) AS posts
+		FROM posts
+		WHERE status = ?
+		GROUP BY day
+		ORDER BY day ASC`,
+		models.Approved,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Leaderboards
+	_, err = db.DB.Query(&stats.TopSenders, `
+		SELECT p.sender AS username, count(*) AS posts
+		FROM posts AS p
+		LEFT JOIN users AS u ON u.username = p.sender
+		WHERE p.status = ?0 AND p.visibility = ?1
+			AND NOT coalesce(u.hidden_from_leaderboard, false)
+		GROUP BY p.sender
+		ORDER BY posts DESC, username ASC
+		LIMIT ?2`,
+		models.Approved, models.Public, top,
+	)
+	if err != nil {
+		return nil, err
+	}
+	_, err = db.DB.Query(&stats.TopRecipients, `
+		SELECT r.username, count(*) AS posts
+		FROM posts AS p
+		CROSS JOIN jsonb_array_elements_text(p.recipients) AS r(username)
+		LEFT JOIN users AS u ON u.username = r.username
+		WHERE p.status = ?0 AND p.visibility = ?1
+			AND NOT coalesce(u.hidden_from_leaderboard, false)
+		GROUP BY r.username
+		ORDER BY posts DESC, username ASC
+		LIMIT ?2`,
+		models.Approved, models.Public, top,
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	// Registration of the seniors
+	var registration struct {
+		Seniors           int
+		RegisteredSeniors int
+	}
+	_, err = db.DB.QueryOne(&registration, `
+		SELECT count(*) AS seniors,
+			count(*) FILTER (WHERE registered) AS registered_seniors
+		FROM users
+		WHERE grade = ?`,
+		models.Senior,
+	)
+	if err != nil {
+		return nil, err
+	}
+	stats.Seniors = registration.Seniors
+	stats.RegisteredSeniors = registration.RegisteredSeniors
+	if stats.Seniors > 0 {
+		stats.RegistrationRate =
+			float64(stats.RegisteredSeniors) / float64(stats.Seniors)
+	}
+
+	return stats, nil
+}
+
+// SetHiddenFromLeaderboard hides a user from the leaderboards or shows
+// them again.
+func (db *Database) SetHiddenFromLeaderboard(username string, hidden bool) error {
+	res, err := db.DB.Model((*models.User)(nil)).
+		Set("hidden_from_leaderboard = ?", hidden).
+		Where("username = ?", username).
+		Update()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
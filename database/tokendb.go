@@ -1,6 +1,7 @@
 package database
 
 import (
+	"github.com/go-pg/pg/v9"
 	"golang.org/x/oauth2"
 )
 
@@ -47,3 +48,20 @@ func (db *Database) GetToken(sub string) (string, error) {
 
 	return token.Token, nil
 }
+
+// RevokeToken deletes the token of the user with the given email, which
+// signs them out everywhere.
+func (db *Database) RevokeToken(email string) error {
+	db.mux.Lock()
+	defer db.mux.Unlock()
+	res, err := db.DB.Model((*token)(nil)).
+		Where("email = ?", email).
+		Delete()
+	if err != nil {
+		return err
+	}
+	if res.RowsAffected() == 0 {
+		return pg.ErrNoRows
+	}
+	return nil
+}
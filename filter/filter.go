@@ -0,0 +1,190 @@
+// Package filter implements the content filter that checks user-submitted
+// text before it is posted.
+package filter
+
+import (
+	"strings"
+	"sync"
+	"unicode"
+)
+
+// DefaultDenyList is the deny list used when no list has been configured.
+// Entries starting or ending with a '*' also match words that contain them.
+var DefaultDenyList = []string{
+	"*fuck*",
+	"*shit*",
+	"dick",
+	"bitch",
+	"ass",
+	"asshole",
+	"cunt",
+	"penis",
+	"vagina",
+	"tits",
+	"cock",
+	"*nigga*",
+	"*nigger*",
+	"fag",
+	"faggot",
+	"pussy",
+}
+
+// suffixes are the word endings that still count as a match for a deny
+// list entry (so that "bitches" matches "bitch").
+var suffixes = []string{"s", "es", "y", "ty", "ed", "er", "ers", "ing", "in"}
+
+// Match describes why a piece of text was rejected.
+type Match struct {
+	Rule string `json:"rule"` // The deny list entry that matched
+	Term string `json:"term"` // The normalized text that matched the rule
+}
+
+// ContentFilter decides whether a piece of text is allowed on the site.
+type ContentFilter interface {
+	// Check returns the rule that the text breaks, or nil if the text is
+	// clean.
+	Check(text string) *Match
+}
+
+// WordFilter is the default ContentFilter. It normalizes the text, splits
+// it into words and matches each word against a deny list, skipping the
+// words on an allow list.
+type WordFilter struct {
+	mux   sync.RWMutex
+	deny  []string
+	allow map[string]bool
+}
+
+// NewWordFilter constructs a new *WordFilter given a deny and an allow list.
+func NewWordFilter(deny, allow []string) *WordFilter {
+	filter := &WordFilter{}
+	filter.SetLists(deny, allow)
+	return filter
+}
+
+// SetLists replaces the deny and allow lists of the filter.
+func (f *WordFilter) SetLists(deny, allow []string) {
+	allowSet := make(map[string]bool)
+	for _, word := range allow {
+		allowSet[deleet(Normalize(strings.TrimSpace(word)))] = true
+	}
+	var denyList []string
+	for _, word := range deny {
+		word = Normalize(strings.TrimSpace(word))
+		if strings.Trim(word, "*") != "" {
+			denyList = append(denyList, word)
+		}
+	}
+
+	f.mux.Lock()
+	defer f.mux.Unlock()
+	f.deny = denyList
+	f.allow = allowSet
+}
+
+// Check checks a piece of text against the deny and allow lists.
+func (f *WordFilter) Check(text string) *Match {
+	f.mux.RLock()
+	defer f.mux.RUnlock()
+
+	for _, word := range candidates(Normalize(text)) {
+		if f.allow[word] {
+			continue
+		}
+		for _, rule := range f.deny {
+			if matches(rule, word) {
+				return &Match{Rule: rule, Term: word}
+			}
+		}
+	}
+	return nil
+}
+
+// candidates splits normalized text into the words that should be checked.
+// Leetspeak is only undone inside of words that contain letters, and runs
+// of single letters ("f u c k", "f.u.c.k") are joined into one word.
+func candidates(text string) []string {
+	chunks := strings.FieldsFunc(text, func(r rune) bool {
+		return !unicode.IsLetter(r) && !unicode.IsDigit(r) && leet[r] == 0
+	})
+
+	var words []string
+	for _, chunk := range chunks {
+		chunk = strings.TrimRight(chunk, "!|") // Trailing punctuation
+		if strings.IndexFunc(chunk, unicode.IsLetter) < 0 {
+			continue // Plain numbers like 2020
+		}
+		words = append(words, strings.FieldsFunc(deleet(chunk), notLetter)...)
+	}
+
+	var joined []string
+	run := ""
+	for _, word := range words {
+		if len([]rune(word)) == 1 {
+			run += word
+			continue
+		}
+		if len(run) > 1 {
+			joined = append(joined, run)
+		}
+		run = ""
+	}
+	if len(run) > 1 {
+		joined = append(joined, run)
+	}
+
+	return append(words, joined...)
+}
+
+// notLetter checks if a rune is not a letter.
+func notLetter(r rune) bool {
+	return !unicode.IsLetter(r)
+}
+
+// matches checks if a single normalized word matches a deny list rule.
+func matches(rule, word string) bool {
+	prefix := strings.HasSuffix(rule, "*")
+	suffix := strings.HasPrefix(rule, "*")
+	base := strings.Trim(rule, "*")
+
+	// Wildcard rules match anywhere they are allowed to
+	switch {
+	case prefix && suffix:
+		return strings.Contains(squeeze(word), squeeze(base))
+	case prefix:
+		return strings.HasPrefix(squeeze(word), squeeze(base))
+	case suffix:
+		return strings.HasSuffix(squeeze(word), squeeze(base))
+	}
+
+	if sameWord(word, base) {
+		return true
+	}
+	for _, s := range suffixes {
+		if strings.HasSuffix(word, s) && sameWord(strings.TrimSuffix(word, s), base) {
+			return true
+		}
+	}
+	return false
+}
+
+// sameWord checks if a word is the base word, allowing for stretched
+// letters ("fuuuck"), but not for missing ones ("as" is not "ass").
+func sameWord(word, base string) bool {
+	return word == base ||
+		(len(word) >= len(base) && squeeze(word) == squeeze(base))
+}
+
+// squeeze collapses repeated letters into a single letter.
+func squeeze(s string) string {
+	var b strings.Builder
+	var last rune
+	for i, r := range s {
+		if i > 0 && r == last {
+			continue
+		}
+		b.WriteRune(r)
+		last = r
+	}
+	return b.String()
+}
@@ -0,0 +1,64 @@
+package filter
+
+import "testing"
+
+func TestCheck(t *testing.T) {
+	filter := NewWordFilter(DefaultDenyList, []string{"shiitake"})
+
+	clean := []string{
+		"Congrats to the whole class of 2020!",
+		"Passing the torch, Mr. Cockburn would be proud",
+		"See you at the assembly, as always",
+		"Shiitake mushrooms forever!!!",
+		"Dickens was our favorite author",
+	}
+	for _, text := range clean {
+		if match := filter.Check(text); match != nil {
+			t.Errorf("%q was rejected by rule %s", text, match.Rule)
+		}
+	}
+
+	dirty := []string{
+		"what the fuck",
+		"FUUUUCK yeah",
+		"f.u.c.k this",
+		"f u c k this",
+		"sh1t happens",
+		"$hit happens",
+		"you absolute ass!",
+		"ｆｕｃｋ",
+		"fúck",
+		"f\u200buck",
+		"bitches",
+		"bullshit",
+	}
+	for _, text := range dirty {
+		if match := filter.Check(text); match == nil {
+			t.Errorf("%q was not rejected", text)
+		}
+	}
+}
+
+func TestCheckRule(t *testing.T) {
+	filter := NewWordFilter([]string{"dick"}, nil)
+
+	match := filter.Check("what a d1ck")
+	if match == nil {
+		t.Fatal("expected a match")
+	}
+	if match.Rule != "dick" || match.Term != "dick" {
+		t.Fatalf("unexpected match %+v", match)
+	}
+}
+
+func TestSetLists(t *testing.T) {
+	filter := NewWordFilter([]string{"heck"}, nil)
+	if filter.Check("heck") == nil {
+		t.Fatal("expected heck to be rejected")
+	}
+
+	filter.SetLists([]string{"heck"}, []string{"heck"})
+	if filter.Check("heck") != nil {
+		t.Fatal("expected heck to be allowed")
+	}
+}
@@ -0,0 +1,85 @@
+package filter
+
+import (
+	"strings"
+	"unicode"
+)
+
+// leet maps common leetspeak substitutions to the letters they stand for.
+var leet = map[rune]rune{
+	'0': 'o',
+	'1': 'i',
+	'3': 'e',
+	'4': 'a',
+	'5': 's',
+	'7': 't',
+	'8': 'b',
+	'@': 'a',
+	'$': 's',
+	'!': 'i',
+	'|': 'i',
+	'+': 't',
+}
+
+// fold maps accented letters and look-alike letters from other scripts to
+// the plain ASCII letters they resemble.
+var fold = map[rune]rune{
+	'à': 'a', 'á': 'a', 'â': 'a', 'ã': 'a', 'ä': 'a', 'å': 'a', 'ā': 'a',
+	'ç': 'c', 'ć': 'c', 'č': 'c',
+	'è': 'e', 'é': 'e', 'ê': 'e', 'ë': 'e', 'ē': 'e', 'ė': 'e', 'ę': 'e',
+	'ì': 'i', 'í': 'i', 'î': 'i', 'ï': 'i', 'ī': 'i', 'ı': 'i',
+	'ñ': 'n', 'ń': 'n',
+	'ò': 'o', 'ó': 'o', 'ô': 'o', 'õ': 'o', 'ö': 'o', 'ø': 'o', 'ō': 'o',
+	'ś': 's', 'š': 's', 'ß': 's',
+	'ù': 'u', 'ú': 'u', 'û': 'u', 'ü': 'u', 'ū': 'u',
+	'ý': 'y', 'ÿ': 'y',
+	'ž': 'z', 'ź': 'z', 'ż': 'z',
+
+	// Cyrillic and Greek look-alikes
+	'а': 'a', 'в': 'b', 'е': 'e', 'к': 'k', 'м': 'm', 'н': 'h', 'о': 'o',
+	'р': 'p', 'с': 'c', 'т': 't', 'у': 'y', 'х': 'x', 'і': 'i',
+	'α': 'a', 'β': 'b', 'ε': 'e', 'ι': 'i', 'κ': 'k', 'ο': 'o', 'ρ': 'p',
+	'τ': 't', 'υ': 'u', 'χ': 'x',
+}
+
+// Normalize lowercases text and undoes common obfuscations: accents,
+// look-alike letters, full-width letters and invisible characters.
+func Normalize(text string) string {
+	var b strings.Builder
+	for _, r := range strings.ToLower(text) {
+		// Full-width forms map directly onto ASCII
+		if r >= 0xFF01 && r <= 0xFF5E {
+			r = unicode.ToLower(r - 0xFEE0)
+		}
+
+		switch {
+		case isInvisible(r):
+			continue
+		case fold[r] != 0:
+			r = fold[r]
+		case unicode.Is(unicode.Mn, r): // Combining accents
+			continue
+		}
+		b.WriteRune(r)
+	}
+	return b.String()
+}
+
+// deleet undoes leetspeak in a single word.
+func deleet(word string) string {
+	return strings.Map(func(r rune) rune {
+		if leet[r] != 0 {
+			return leet[r]
+		}
+		return r
+	}, word)
+}
+
+// isInvisible checks if a rune is a zero-width or formatting character.
+func isInvisible(r rune) bool {
+	switch r {
+	case '\u200b', '\u200c', '\u200d', '\u2060', '\ufeff', '\u00ad':
+		return true
+	}
+	return unicode.Is(unicode.Cf, r)
+}
@@ -0,0 +1,162 @@
+// Package mail implements the mailers that deliver email notifications.
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	"io/ioutil"
+	"mime"
+	"mime/multipart"
+	"net/smtp"
+	"net/textproto"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// Message represents an email message with a plain-text and an HTML
+// version of its body.
+type Message struct {
+	To      []string `json:"to"`
+	Subject string   `json:"subject"`
+	Text    string   `json:"text"`
+	HTML    string   `json:"html"`
+
+	Headers map[string]string `json:"headers"` // Any extra headers
+}
+
+// Mailer delivers email messages.
+type Mailer interface {
+	// Send delivers a message, returning an error if it could not be
+	// delivered.
+	Send(msg Message) error
+}
+
+// Bytes formats a message as a multipart/alternative email from a given
+// address. If the message only has one version of its body, the email is
+// not multipart.
+func (msg Message) Bytes(from string) []byte {
+	var b bytes.Buffer
+	header := func(key, value string) {
+		fmt.Fprintf(&b, "%s: %s\r\n", key, stripNewlines(value))
+	}
+
+	header("From", from)
+	header("To", strings.Join(msg.To, ","))
+	header("Subject", mime.QEncoding.Encode("utf-8", msg.Subject))
+	header("Date", time.Now().Format(time.RFC1123Z))
+	header("MIME-Version", "1.0")
+
+	// Extra headers are written in a stable order
+	var keys []string
+	for key := range msg.Headers {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		header(key, msg.Headers[key])
+	}
+
+	switch {
+	case msg.HTML == "":
+		header("Content-Type", "text/plain; charset=\"UTF-8\"")
+		fmt.Fprintf(&b, "\r\n%s\r\n", msg.Text)
+	case msg.Text == "":
+		header("Content-Type", "text/html; charset=\"UTF-8\"")
+		fmt.Fprintf(&b, "\r\n%s\r\n", msg.HTML)
+	default:
+		parts := multipart.NewWriter(&b)
+		header("Content-Type",
+			"multipart/alternative; boundary=\""+parts.Boundary()+"\"")
+		b.WriteString("\r\n")
+		writePart(parts, "text/plain", msg.Text)
+		writePart(parts, "text/html", msg.HTML)
+		parts.Close()
+	}
+	return b.Bytes()
+}
+
+// writePart writes one version of a message body as a part.
+func writePart(parts *multipart.Writer, contentType, body string) {
+	part, _ := parts.CreatePart(textproto.MIMEHeader{
+		"Content-Type": {contentType + "; charset=\"UTF-8\""},
+	})
+	part.Write([]byte(body))
+}
+
+// stripNewlines removes newlines from a header value so that it cannot be
+// used to add headers.
+func stripNewlines(s string) string {
+	return strings.NewReplacer("\r", "", "\n", "").Replace(s)
+}
+
+// SMTPMailer is a Mailer that delivers messages through an SMTP server.
+type SMTPMailer struct {
+	Host     string
+	Port     int
+	From     string // Also used as the username
+	Password string
+}
+
+// Send sends a message through the SMTP server.
+func (m *SMTPMailer) Send(msg Message) error {
+	auth := smtp.PlainAuth("", m.From, m.Password, m.Host)
+	return smtp.SendMail(
+		fmt.Sprintf("%s:%d", m.Host, m.Port),
+		auth, m.From, msg.To, msg.Bytes(m.From),
+	)
+}
+
+// MemoryMailer is a Mailer that keeps every message in memory. It is
+// meant for tests.
+type MemoryMailer struct {
+	mux  sync.Mutex
+	sent []Message
+}
+
+// Send stores a message.
+func (m *MemoryMailer) Send(msg Message) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	m.sent = append(m.sent, msg)
+	return nil
+}
+
+// Sent returns every message sent so far.
+func (m *MemoryMailer) Sent() []Message {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+	return append([]Message(nil), m.sent...)
+}
+
+// FileMailer is a Mailer that writes every message to a file in a
+// directory instead of sending it.
+type FileMailer struct {
+	Dir  string
+	From string
+
+	mux   sync.Mutex
+	count int
+}
+
+// Send writes a message to a new .eml file.
+func (m *FileMailer) Send(msg Message) error {
+	m.mux.Lock()
+	defer m.mux.Unlock()
+
+	err := os.MkdirAll(filepath.FromSlash(m.Dir), 0755)
+	if err != nil {
+		return err
+	}
+
+	m.count++
+	name := fmt.Sprintf("%s_%04d.eml",
+		time.Now().Format("2006-01-02_15-04-05"), m.count)
+	return ioutil.WriteFile(
+		filepath.Join(filepath.FromSlash(m.Dir), name),
+		msg.Bytes(m.From), 0644,
+	)
+}
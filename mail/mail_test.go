@@ -0,0 +1,102 @@
+package mail
+
+import (
+	"io/ioutil"
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestMemoryMailer(t *testing.T) {
+	mailer := &MemoryMailer{}
+	err := mailer.Send(Message{To: []string{"a@b.c"}, Subject: "Hi"})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if len(mailer.Sent()) != 1 || mailer.Sent()[0].Subject != "Hi" {
+		t.Fatalf("unexpected sent messages %v", mailer.Sent())
+	}
+}
+
+func TestFileMailer(t *testing.T) {
+	dir, err := ioutil.TempDir("", "mail")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	mailer := &FileMailer{Dir: dir, From: "from@b.c"}
+	err = mailer.Send(Message{
+		To:      []string{"a@b.c"},
+		Subject: "Hi",
+		Text:    "Hello there",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	files, err := ioutil.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(files) != 1 {
+		t.Fatalf("expected 1 file, got %d", len(files))
+	}
+
+	contents, err := ioutil.ReadFile(dir + "/" + files[0].Name())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(contents), "Subject: Hi") ||
+		!strings.Contains(string(contents), "Hello there") {
+		t.Fatalf("unexpected email %s", contents)
+	}
+}
+
+func TestBytesMultipart(t *testing.T) {
+	msg := Message{
+		To:      []string{"a@b.c"},
+		Subject: "Hi\r\nBcc: evil@b.c",
+		Text:    "Hello there",
+		HTML:    "<p>Hello there</p>",
+	}
+	email := string(msg.Bytes("from@b.c"))
+
+	if strings.Contains(email, "\r\nBcc:") {
+		t.Fatal("subject was able to add a header")
+	}
+	if !strings.Contains(email, "multipart/alternative") ||
+		!strings.Contains(email, "text/plain") ||
+		!strings.Contains(email, "text/html") {
+		t.Fatalf("expected a multipart/alternative email, got %s", email)
+	}
+}
+
+func TestRender(t *testing.T) {
+	msg, err := Render(NewPostTemplate, NewPostData{
+		Sender:  "<script>alert(1)</script>",
+		Message: "Congrats!",
+		PostURL: "https://mastersseniors2020.com/post/abc",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	if strings.Contains(msg.HTML, "<script>") {
+		t.Fatal("sender name was not escaped")
+	}
+	if !strings.Contains(msg.HTML, "https://mastersseniors2020.com/post/abc") ||
+		!strings.Contains(msg.Text, "https://mastersseniors2020.com/post/abc") {
+		t.Fatal("missing link to the post")
+	}
+}
+
+func TestRenderSamples(t *testing.T) {
+	for _, name := range TemplateNames() {
+		_, err := Render(name, SampleData(name))
+		if err != nil {
+			t.Fatalf("%s: %s", name, err)
+		}
+	}
+}
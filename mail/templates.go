@@ -0,0 +1,348 @@
+package mail
+
+import (
+	"bytes"
+	"fmt"
+	htmltemplate "html/template"
+	"sort"
+	texttemplate "text/template"
+)
+
+// The names of the email templates, one per event.
+const (
+	NewPostTemplate         = "new_post"
+	ModerationTemplate      = "moderation_result"
+	DigestTemplate          = "digest"
+	ModReviewTemplate       = "mod_review"
+	RecipientActionTemplate = "recipient_action"
+	ReactionTemplate        = "reaction"
+	ReciprocateTemplate     = "reciprocate"
+)
+
+// Footer is the data shared by every template. It is embedded in the data
+// of each template.
+type Footer struct {
+	UnsubscribeURL string // Left empty for emails that are not to users
+}
+
+// NewPostData is the data for the new post template.
+type NewPostData struct {
+	Footer
+
+	Sender  string // The name of the sender
+	Message string
+	PostURL string
+}
+
+// ModerationData is the data for the moderation result template.
+type ModerationData struct {
+	Footer
+	Approved bool
+	Reason   string
+	Message  string
+	PostURL  string
+}
+
+// DigestPost is a single post listed in a digest.
+type DigestPost struct {
+	Sender  string
+	Excerpt string
+	PostURL string
+}
+
+// DigestData is the data for the digest template.
+type DigestData struct {
+	Footer
+	Name    string // The name of the recipient of the digest
+	Posts   []DigestPost
+	SiteURL string
+}
+
+// ModReviewData is the data for the moderator review template.
+type ModReviewData struct {
+	Footer
+	Sender     string
+	Recipients []string
+	Message    string
+	PostID     string
+	PostURL    string
+}
+
+// RecipientActionData is the data for the recipient action template.
+type RecipientActionData struct {
+	Footer
+	Recipient string
+	Action    string // "hid" or "removed themselves from"
+	Message   string
+	PostURL   string
+}
+
+// ReactionData is the data for the reaction template.
+type ReactionData struct {
+	Footer
+	Reactor string // The name of the user who reacted
+	Emoji   string
+	Message string
+	PostURL string
+}
+
+// ReciprocatePerson is a single person listed in a reciprocity reminder.
+type ReciprocatePerson struct {
+	Name       string
+	ComposeURL string // Starts a post to them
+}
+
+// ReciprocateData is the data for the weekly reciprocity reminder
+// template.
+type ReciprocateData struct {
+	Footer
+	Name    string // The name of the recipient of the reminder
+	People  []ReciprocatePerson
+	SiteURL string
+}
+
+// layout is the HTML layout that every HTML template is rendered into.
+const layout = `{{define "layout"}}<!DOCTYPE html>
+<html>
+<head><meta content="text/html; charset=utf-8" http-equiv="Content-Type"/><meta content="width=device-width" name="viewport"/></head>
+<body style="margin: 0; padding: 0; font-family: Roboto, Tahoma, Verdana, Segoe, sans-serif; color: #555555;">
+<div style="max-width: 625px; margin: 0 auto;">
+<div style="background-color: #8321fd; padding: 35px 10px; text-align: center;"><strong style="font-size: 24px; color: #ffffff;">Masters Seniors 2020 Notification</strong></div>
+<div style="padding: 15px 10px; font-size: 17px; line-height: 1.2;">{{template "content" .}}</div>
+{{if .UnsubscribeURL}}<div style="padding: 15px 10px; font-size: 12px; text-align: center;"><a href="{{.UnsubscribeURL}}" style="color: #999999;">Unsubscribe from these emails</a></div>{{end}}
+</div>
+</body>
+</html>{{end}}`
+
+// textFooter is appended to every plain-text template.
+const textFooter = `{{if .UnsubscribeURL}}
+
+--
+Unsubscribe from these emails: {{.UnsubscribeURL}}{{end}}`
+
+// link is the style of every link in the HTML templates.
+const link = `style="text-decoration: underline; color: #00a1ff;" rel="noopener" target="_blank"`
+
+// sources holds the subject, plain-text and HTML source of each template.
+var sources = map[string][3]string{
+	NewPostTemplate: {
+		`{{.Sender}} Congratulated you!`,
+		`Congratulations! {{.Sender}} congratulated you on MastersSeniors2020.com!
+
+"{{.Message}}"
+
+View {{.Sender}}'s post about you: {{.PostURL}}
+
+Return the favor by congratulating one of your senior friends!`,
+		`<p>Congratulations! {{.Sender}} congratulated you on <a href="{{.PostURL}}" ` + link + `>MastersSeniors2020.com</a>!</p>
+<blockquote>{{.Message}}</blockquote>
+<p>Want to view {{.Sender}}'s post about you? <a href="{{.PostURL}}" ` + link + `>Click here</a>! Return the favor by congratulating one of your senior friends!</p>`,
+	},
+	ModerationTemplate: {
+		`Your post was {{if .Approved}}approved{{else}}not approved{{end}}`,
+		`{{if .Approved}}Your post is now live!{{else}}Your post was not approved by the moderators.{{end}}
+
+"{{.Message}}"
+{{if .Reason}}
+Reason: {{.Reason}}
+{{end}}{{if .Approved}}
+View it here: {{.PostURL}}{{end}}`,
+		`<p>{{if .Approved}}Your post is now live!{{else}}Your post was not approved by the moderators.{{end}}</p>
+<blockquote>{{.Message}}</blockquote>
+{{if .Reason}}<p>Reason: {{.Reason}}</p>{{end}}
+{{if .Approved}}<p><a href="{{.PostURL}}" ` + link + `>View your post</a></p>{{end}}`,
+	},
+	DigestTemplate: {
+		`You were congratulated {{len .Posts}} time{{if ne (len .Posts) 1}}s{{end}} today!`,
+		`Hi {{.Name}}, here is what people said about you today:
+{{range .Posts}}
+{{.Sender}}: "{{.Excerpt}}"
+{{.PostURL}}
+{{end}}
+See everything at {{.SiteURL}}`,
+		`<p>Hi {{.Name}}, here is what people said about you today:</p>
+<ul>{{range .Posts}}
+<li><strong>{{.Sender}}</strong>: &ldquo;{{.Excerpt}}&rdquo; <a href="{{.PostURL}}" ` + link + `>View</a></li>{{end}}
+</ul>
+<p><a href="{{.SiteURL}}" ` + link + `>See everything on MastersSeniors2020.com</a></p>`,
+	},
+	ModReviewTemplate: {
+		`{{.Sender}} posted (needs review)`,
+		`Sender: {{.Sender}}
+Recipients: {{.Recipients}}
+Message: {{.Message}}
+PostID: {{.PostID}}
+{{.PostURL}}`,
+		`<p><strong>Sender:</strong> {{.Sender}}<br/>
+<strong>Recipients:</strong> {{range $i, $r := .Recipients}}{{if $i}}, {{end}}{{$r}}{{end}}<br/>
+<strong>PostID:</strong> {{.PostID}}</p>
+<blockquote>{{.Message}}</blockquote>
+<p><a href="{{.PostURL}}" ` + link + `>Review the post</a></p>`,
+	},
+	RecipientActionTemplate: {
+		`{{.Recipient}} {{.Action}} your post`,
+		`{{.Recipient}} {{.Action}} your post:
+
+"{{.Message}}"
+
+{{.PostURL}}`,
+		`<p>{{.Recipient}} {{.Action}} your post:</p>
+<blockquote>{{.Message}}</blockquote>
+<p><a href="{{.PostURL}}" ` + link + `>View your post</a></p>`,
+	},
+	ReactionTemplate: {
+		`{{.Reactor}} reacted {{.Emoji}} to your post`,
+		`{{.Reactor}} reacted {{.Emoji}} to your post:
+
+"{{.Message}}"
+
+{{.PostURL}}`,
+		`<p>{{.Reactor}} reacted {{.Emoji}} to your post:</p>
+<blockquote>{{.Message}}</blockquote>
+<p><a href="{{.PostURL}}" ` + link + `>View your post</a></p>`,
+	},
+	ReciprocateTemplate: {
+		`Return the favor to {{len .People}} friend{{if ne (len .People) 1}}s{{end}}`,
+		`Hi {{.Name}}, these people congratulated you, but you have not congratulated them back yet:
+{{range .People}}
+{{.Name}}: {{.ComposeURL}}
+{{end}}
+See everything at {{.SiteURL}}`,
+		`<p>Hi {{.Name}}, these people congratulated you, but you have not congratulated them back yet:</p>
+<ul>{{range .People}}
+<li><strong>{{.Name}}</strong> <a href="{{.ComposeURL}}" ` + link + `>Congratulate them</a></li>{{end}}
+</ul>
+<p><a href="{{.SiteURL}}" ` + link + `>See everything on MastersSeniors2020.com</a></p>`,
+	},
+}
+
+// Template is an email template with a subject, a plain-text body and an
+// HTML body.
+type Template struct {
+	subject *texttemplate.Template
+	text    *texttemplate.Template
+	html    *htmltemplate.Template
+}
+
+// templates holds every parsed template by name.
+var templates = make(map[string]*Template)
+
+func init() {
+	for name, source := range sources {
+		html := htmltemplate.Must(htmltemplate.New(name).Parse(layout))
+		templates[name] = &Template{
+			subject: texttemplate.Must(texttemplate.New(name).Parse(source[0])),
+			text: texttemplate.Must(
+				texttemplate.New(name).Parse(source[1] + textFooter),
+			),
+			html: htmltemplate.Must(
+				html.New("content").Parse(source[2]),
+			).Lookup("layout"),
+		}
+	}
+}
+
+// Render renders a template with the given data into a message. The
+// recipients of the message are left empty.
+func Render(name string, data interface{}) (Message, error) {
+	t, ok := templates[name]
+	if !ok {
+		return Message{}, fmt.Errorf("unknown email template '%s'", name)
+	}
+
+	var subject, text, html bytes.Buffer
+	err := t.subject.Execute(&subject, data)
+	if err != nil {
+		return Message{}, err
+	}
+	err = t.text.Execute(&text, data)
+	if err != nil {
+		return Message{}, err
+	}
+	err = t.html.Execute(&html, data)
+	if err != nil {
+		return Message{}, err
+	}
+
+	return Message{
+		Subject: subject.String(),
+		Text:    text.String(),
+		HTML:    html.String(),
+	}, nil
+}
+
+// TemplateNames returns the names of every template.
+func TemplateNames() []string {
+	var names []string
+	for name := range templates {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// SampleData returns sample data for a template, used to preview it.
+func SampleData(name string) interface{} {
+	postURL := "https://mastersseniors2020.com/post/sample"
+	footer := Footer{"https://mastersseniors2020.com/api/unsubscribe?token=sample"}
+	switch name {
+	case NewPostTemplate:
+		return NewPostData{
+			Footer:  footer,
+			Sender:  "Matthew Nappo",
+			Message: "Congrats on graduating!",
+			PostURL: postURL,
+		}
+	case ModerationTemplate:
+		return ModerationData{
+			Footer:  footer,
+			Reason:  "Please keep it kind.",
+			Message: "Congrats!",
+			PostURL: postURL,
+		}
+	case DigestTemplate:
+		return DigestData{
+			Footer: footer,
+			Name:   "Jane Doe",
+			Posts: []DigestPost{
+				{"Matthew Nappo", "Congrats on graduating!", postURL},
+				{"John Smith", "I will miss you next year...", postURL},
+			},
+			SiteURL: "https://mastersseniors2020.com",
+		}
+	case ModReviewTemplate:
+		return ModReviewData{
+			Sender:     "matthew.nappo",
+			Recipients: []string{"jane.doe"},
+			Message:    "Congrats!",
+			PostID:     "sample",
+			PostURL:    postURL,
+		}
+	case RecipientActionTemplate:
+		return RecipientActionData{
+			Footer:    footer,
+			Recipient: "Jane Doe",
+			Action:    "hid",
+			Message:   "Congrats!",
+			PostURL:   postURL,
+		}
+	case ReactionTemplate:
+		return ReactionData{
+			Footer:  footer,
+			Reactor: "Jane Doe",
+			Emoji:   "❤️",
+			Message: "Congrats!",
+			PostURL: postURL,
+		}
+	case ReciprocateTemplate:
+		return ReciprocateData{
+			Footer: footer,
+			Name:   "Jane Doe",
+			People: []ReciprocatePerson{
+				{"Matthew Nappo", "https://mastersseniors2020.com/create?to=matthew.nappo"},
+			},
+			SiteURL: "https://mastersseniors2020.com",
+		}
+	}
+	return nil
+}
@@ -5,6 +5,7 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
+	"regexp"
 	"strings"
 	"time"
 
@@ -14,6 +15,10 @@ import (
 
 var (
 	errInvalidEmail = errors.New("malformed email address")
+
+	// mentionPattern matches a mention of a username, such as
+	// "@first.last", that is not part of an email address or another word.
+	mentionPattern = regexp.MustCompile(`(^|[^\w@.])@([\w-]+\.[\w-]+)`)
 )
 
 // Username represents a username.
@@ -33,8 +38,83 @@ const (
 	Junior = iota
 	// Senior represents a senior.
 	Senior = iota
+	// Faculty represents a member of the faculty.
+	Faculty = iota
+)
+
+// gradeNames are the names of the grades, in order.
+var gradeNames = []string{"freshman", "sophomore", "junior", "senior", "faculty"}
+
+// ParseGrade parses the name of a grade, such as "senior".
+func ParseGrade(name string) (Grade, error) {
+	for grade, gradeName := range gradeNames {
+		if strings.ToLower(name) == gradeName {
+			return Grade(grade), nil
+		}
+	}
+	return 0, fmt.Errorf("invalid grade '%s'", name)
+}
+
+// String returns the name of a grade.
+func (grade Grade) String() string {
+	if grade < 0 || int(grade) >= len(gradeNames) {
+		return fmt.Sprintf("Grade(%d)", int(grade))
+	}
+	return gradeNames[grade]
+}
+
+// Role is a user role enum.
+type Role int
+
+const (
+	// Student represents a regular user.
+	Student = iota
+	// Moderator represents a user who can moderate posts.
+	Moderator = iota
+	// Admin represents a user who can moderate posts and manage the site.
+	Admin = iota
+)
+
+// Status is a post status enum.
+type Status int
+
+const (
+	// Pending represents a post waiting to be reviewed by a moderator.
+	Pending = iota
+	// Approved represents a post that is live.
+	Approved = iota
+	// Rejected represents a post that was rejected by a moderator.
+	Rejected = iota
+	// Draft represents a post that is saved but not published.
+	Draft = iota
+	// Scheduled represents a post that will be published at its PublishAt
+	// time.
+	Scheduled = iota
+)
+
+// Visibility is who can see a post.
+type Visibility string
+
+const (
+	// Public posts can be seen by everyone.
+	Public Visibility = "public"
+
+	// RecipientsOnly posts can only be seen by their recipients.
+	RecipientsOnly Visibility = "recipients"
+
+	// Private posts, or private notes, can only be seen by their sender
+	// and their recipients.
+	Private Visibility = "private"
 )
 
+// Viewer is a user reading posts. Moderators can see any single post so
+// that they can review it, but lists of posts never include posts that a
+// moderator could not see as a regular user.
+type Viewer struct {
+	Username  Username
+	Moderator bool
+}
+
 // User represents a user.
 type User struct {
 	ID       int32    `pg:",pk" json:"id"`
@@ -44,6 +124,7 @@ type User struct {
 	Lastname     string    `pg:",notnull" json:"lastname"`
 	Email        string    `pg:",notnull,unique" json:"email"`
 	Grade        Grade     `pg:",use_zero" json:"grade"`
+	Role         Role      `pg:",use_zero" json:"role"`
 	RegisterDate time.Time `pg:",notnull" json:"register_date"`
 
 	// Mutable fields
@@ -53,8 +134,141 @@ type User struct {
 	Will       string `json:"will"`
 	Registered bool   `json:"registered"`
 
+	// DoNotAcceptPosts stops anyone from posting about the user. Only
+	// non-seniors and faculty can turn it on.
+	DoNotAcceptPosts bool `json:"do_not_accept_posts"`
+
+	// OpenToSuggestions lets other users be asked to write to the user
+	// when the user has few posts. Only seniors can turn it on.
+	OpenToSuggestions bool `json:"open_to_suggestions"`
+
+	// HiddenFromLeaderboard leaves the user off of the leaderboards. Only
+	// admins can set it.
+	HiddenFromLeaderboard bool `json:"hidden_from_leaderboard"`
+
+	// Blocked is the list of users that may not post about this user. It
+	// is never sent to other users.
+	Blocked []Username `json:"-"`
+
 	OutboundPosts []string `json:"outbound_posts"` // postIDs from this user
 	InboundPosts  []string `json:"inbound_posts"`  // postIDs to this user
+	HiddenPosts   []string `json:"hidden_posts"`   // postIDs hidden by this user
+}
+
+// UserSuggestion is a user suggested as a recipient while searching the
+// user directory.
+type UserSuggestion struct {
+	Username   Username `json:"username"`
+	Firstname  string   `json:"firstname"`
+	Lastname   string   `json:"lastname"`
+	Nickname   string   `json:"nickname"`
+	Grade      Grade    `json:"grade"`
+	ProfilePic string   `json:"profile_pic"` // A url
+
+	// Correspondent is true if the user has exchanged posts with the
+	// user searching.
+	Correspondent bool `json:"correspondent"`
+}
+
+// Coverage is how many live posts a senior has received, and from how
+// many different senders.
+type Coverage struct {
+	Username  Username `json:"username"`
+	Firstname string   `json:"firstname"`
+	Lastname  string   `json:"lastname"`
+	Posts     int      `json:"posts"`
+	Senders   int      `json:"senders"`
+}
+
+// Stats are statistics about the posts and users of the whole class.
+type Stats struct {
+	Posts         int        `json:"posts"`
+	PostsByDay    []DayCount `json:"posts_by_day"`
+	Images        int        `json:"images"`
+	AvgRecipients float64    `json:"avg_recipients"` // Per post
+
+	TopSenders    []LeaderboardEntry `json:"top_senders"`
+	TopRecipients []LeaderboardEntry `json:"top_recipients"`
+
+	Seniors           int     `json:"seniors"`
+	RegisteredSeniors int     `json:"registered_seniors"`
+	RegistrationRate  float64 `json:"registration_rate"` // From 0 to 1
+
+	ComputedAt time.Time `json:"computed_at"`
+}
+
+// DayCount is the number of posts made on one day.
+type DayCount struct {
+	Day   time.Time `json:"day"`
+	Posts int       `json:"posts"`
+}
+
+// LeaderboardEntry is a user on a leaderboard.
+type LeaderboardEntry struct {
+	Username Username `json:"username"`
+	Posts    int      `json:"posts"`
+}
+
+// UsageEvent is a single authenticated request, recorded for usage
+// analytics.
+type UsageEvent struct {
+	ID       int64    `pg:",pk" json:"id"`
+	Username Username `pg:",notnull" json:"username"`
+	Method   string   `pg:",notnull" json:"method"`
+	Route    string   `pg:",notnull" json:"route"` // The route pattern
+
+	Timestamp time.Time `pg:",notnull" json:"timestamp"`
+
+	// Aggregated is true once the event is counted in the daily usage
+	Aggregated bool `pg:",use_zero" json:"aggregated"`
+}
+
+// DailyUsage is the number of requests a user made to a route in a day.
+type DailyUsage struct {
+	ID       int64     `pg:",pk" json:"id"`
+	Day      time.Time `pg:",notnull,unique:day_user_route" json:"day"`
+	Username Username  `pg:",notnull,unique:day_user_route" json:"username"`
+	Method   string    `pg:",notnull,unique:day_user_route" json:"method"`
+	Route    string    `pg:",notnull,unique:day_user_route" json:"route"`
+	Requests int       `pg:",use_zero" json:"requests"`
+}
+
+// UsageReport is the report of how many users use the site and which
+// routes they use.
+type UsageReport struct {
+	DAU int `json:"dau"` // Users active in the last day
+	WAU int `json:"wau"` // In the last week
+	MAU int `json:"mau"` // In the last 30 days
+
+	DailyActive []ActiveDay  `json:"daily_active"` // Over the last 30 days
+	Routes      []RouteUsage `json:"routes"`       // Most popular first
+}
+
+// ActiveDay is the number of users active on one day.
+type ActiveDay struct {
+	Day   time.Time `json:"day"`
+	Users int       `json:"users"`
+}
+
+// RouteUsage is how much a route was used over the last 30 days.
+type RouteUsage struct {
+	Method   string `json:"method"`
+	Route    string `json:"route"`
+	Requests int    `json:"requests"`
+	Users    int    `json:"users"`
+}
+
+// Reciprocation is someone who sent posts to a user that the user has not
+// sent a post back to.
+type Reciprocation struct {
+	Username   Username  `json:"username"`
+	Firstname  string    `json:"firstname"`
+	Lastname   string    `json:"lastname"`
+	ProfilePic string    `json:"profile_pic"`  // A url
+	Posts      int       `json:"posts"`        // How many posts they sent
+	LastPostAt time.Time `json:"last_post_at"` // When they sent the latest
+
+	ComposeURL string `pg:"-" json:"compose_url"` // Starts a post to them
 }
 
 // Post represents a post in the database.
@@ -68,6 +282,219 @@ type Post struct {
 
 	Message string  `pg:",notnull" json:"message"`
 	Images  []image `pg:",array" json:"images"`
+
+	// Moderation fields
+	Status           Status   `pg:",use_zero" json:"status"`
+	Flagged          bool     `json:"flagged"` // Flagged by the content filter
+	ModeratedBy      Username `json:"moderated_by"`
+	ModerationReason string   `json:"moderation_reason"`
+
+	Visibility Visibility `pg:",notnull" json:"visibility"`
+
+	// Mentions are the mentions of known users in the message
+	Mentions []Mention `json:"mentions"`
+
+	// PublishAt is when a scheduled post will be published. It is zero for
+	// posts that were published right away.
+	PublishAt time.Time `json:"publish_at"`
+
+	// LiveAt is when the post first went live. It is zero for posts that
+	// have not been approved yet.
+	LiveAt time.Time `json:"live_at"`
+
+	// ReactionNotified is whether the sender has been notified of a
+	// reaction to the post, which only happens once.
+	ReactionNotified bool `json:"-"`
+
+	// Reaction fields, filled in for the user viewing the post
+	Reactions   map[string]int `pg:"-" json:"reactions"`    // Counts by emoji
+	MyReactions []string       `pg:"-" json:"my_reactions"` // The viewer's
+}
+
+// Mention is a mention of a user in the message of a post, such as
+// "@first.last". Start and End are the offsets of the mention in the
+// message in UTF-16 code units, the way JavaScript indexes strings, and
+// Text is the mention as written, including the @.
+type Mention struct {
+	Username Username `json:"username"`
+	Text     string   `json:"text"`
+	Start    int      `json:"start"`
+	End      int      `json:"end"`
+}
+
+// Reaction represents a user's emoji reaction to a post.
+type Reaction struct {
+	ID       int64    `pg:",pk" json:"id"`
+	PostID   string   `pg:",notnull,unique:post_user_emoji" json:"post_id"`
+	Username Username `pg:",notnull,unique:post_user_emoji" json:"username"`
+	Emoji    string   `pg:",notnull,unique:post_user_emoji" json:"emoji"`
+
+	Timestamp time.Time `pg:",notnull" json:"timestamp"`
+}
+
+// Comment represents a comment on a post. A comment with a ParentID is a
+// reply to another comment. Replies can not be replied to.
+type Comment struct {
+	ID       int64    `pg:",pk" json:"id"`
+	PostID   string   `pg:",notnull" json:"post_id"`
+	ParentID int64    `json:"parent_id"` // Zero for top-level comments
+	Author   Username `pg:",notnull" json:"author"`
+	Message  string   `pg:",notnull" json:"message"`
+
+	Timestamp time.Time `pg:",notnull" json:"timestamp"`
+	EditedAt  time.Time `json:"edited_at"` // Zero if never edited
+
+	Replies []Comment `pg:"-" json:"replies"` // Filled in for top-level comments
+}
+
+// ReportCategory is the category of the reason a post was reported.
+type ReportCategory string
+
+const (
+	// Harassment is a report of bullying or harassment.
+	Harassment ReportCategory = "harassment"
+	// Inappropriate is a report of inappropriate content.
+	Inappropriate ReportCategory = "inappropriate"
+	// Impersonation is a report of a post pretending to be someone else.
+	Impersonation ReportCategory = "impersonation"
+	// Spam is a report of spam.
+	Spam ReportCategory = "spam"
+	// Other is a report for any other reason.
+	Other ReportCategory = "other"
+)
+
+// ReportStatus is a report status enum.
+type ReportStatus int
+
+const (
+	// Open represents a report that has not been reviewed.
+	Open = iota
+	// Resolved represents a report that a moderator acted on.
+	Resolved = iota
+	// Dismissed represents a report that a moderator dismissed.
+	Dismissed = iota
+)
+
+// Report represents a report of a post by a user.
+type Report struct {
+	ID       int32    `pg:",pk" json:"id"`
+	PostID   string   `pg:",notnull,unique:post_reporter" json:"post_id"`
+	Reporter Username `pg:",notnull,unique:post_reporter" json:"reporter"`
+
+	Category  ReportCategory `pg:",notnull" json:"category"`
+	Reason    string         `json:"reason"`
+	Timestamp time.Time      `pg:",notnull" json:"timestamp"`
+
+	// Review fields
+	Status     ReportStatus `pg:",use_zero" json:"status"`
+	ReviewedBy Username     `json:"reviewed_by"`
+	ReviewNote string       `json:"review_note"`
+}
+
+// NotificationStatus is the delivery status of an email notification.
+type NotificationStatus int
+
+const (
+	// Queued represents a notification waiting to be sent.
+	Queued = iota
+	// Sending represents a notification that a worker is sending.
+	Sending = iota
+	// Sent represents a notification that was delivered.
+	Sent = iota
+	// Failed represents a notification that failed to send and will be
+	// retried.
+	Failed = iota
+	// Dead represents a notification that failed too many times and will
+	// not be retried unless an admin asks for it.
+	Dead = iota
+)
+
+// Notification represents an email notification in the outbox.
+type Notification struct {
+	ID   int64  `pg:",pk" json:"id"`
+	Kind string `pg:",notnull" json:"kind"` // The event that caused it
+
+	To      []string `pg:",notnull" json:"to"`
+	Subject string   `pg:",notnull" json:"subject"`
+	Text    string   `pg:",notnull" json:"text"` // The plain-text body
+	HTML    string   `json:"html"`               // The HTML body
+
+	Unsubscribe string `json:"unsubscribe"` // One-click unsubscribe URL
+
+	// Delivery fields
+	Status      NotificationStatus `pg:",use_zero" json:"status"`
+	Attempts    int                `pg:",use_zero" json:"attempts"`
+	NextAttempt time.Time          `pg:",notnull" json:"next_attempt"`
+	LastError   string             `json:"last_error"`
+	CreatedAt   time.Time          `pg:",notnull" json:"created_at"`
+	SentAt      time.Time          `json:"sent_at"`
+}
+
+// Cadence is how often a user is emailed about an event.
+type Cadence string
+
+const (
+	// Immediate emails the user as soon as the event happens.
+	Immediate Cadence = "immediate"
+	// Daily emails the user a daily digest of the events.
+	Daily Cadence = "daily"
+	// Never does not email the user about the event.
+	Never Cadence = "never"
+)
+
+// NotificationPreferences represents a user's email notification
+// settings.
+type NotificationPreferences struct {
+	Username     Username `pg:",pk" json:"username"`
+	EmailEnabled bool     `pg:",use_zero" json:"email_enabled"`
+
+	// Cadences maps an event to how often the user is emailed about it.
+	// Events that are not in the map are emailed immediately.
+	Cadences map[string]Cadence `json:"cadences"`
+
+	// LastDigest is when the user's inbound posts were last checked for a
+	// daily digest. Only newer posts go in the next digest.
+	LastDigest time.Time `json:"last_digest"`
+
+	// LastReminder is when the user was last sent the weekly reminder to
+	// congratulate the people who congratulated them.
+	LastReminder time.Time `json:"last_reminder"`
+}
+
+// InAppNotification represents an event shown in a user's in-app
+// notification feed.
+type InAppNotification struct {
+	ID       int64    `pg:",pk" json:"id"`
+	Username Username `pg:",notnull" json:"username"` // Who it is for
+	Kind     string   `pg:",notnull" json:"kind"`     // The event
+
+	Actor   Username `json:"actor"`   // Who caused it
+	PostID  string   `json:"post_id"` // The post it is about, if any
+	Message string   `json:"message"`
+
+	Read      bool      `pg:",use_zero" json:"read"`
+	Timestamp time.Time `pg:",notnull" json:"timestamp"`
+}
+
+// FilterList is the name of a content filter list.
+type FilterList string
+
+const (
+	// DenyList is the list of words that are not allowed in posts.
+	DenyList FilterList = "deny"
+
+	// AllowList is the list of words that are always allowed in posts,
+	// even if they match a rule on the deny list.
+	AllowList FilterList = "allow"
+)
+
+// FilterWord represents an entry of a content filter list.
+type FilterWord struct {
+	Word string     `pg:",pk" json:"word"`
+	List FilterList `pg:",notnull" json:"list"`
+
+	AddedBy   Username  `json:"added_by"`
+	Timestamp time.Time `pg:",notnull" json:"timestamp"`
 }
 
 // NewUser creates a *User given a valid email and grade.
@@ -94,12 +521,13 @@ func NewPost(
 	message string,
 	images []string,
 	recipientsUsernames []string,
+	limits common.Limits,
 ) (*Post, error) {
 	// Check that all data for the post is valid
-	if len(recipientsUsernames) > common.MaxRecipients ||
+	if len(recipientsUsernames) > limits.MaxRecipients ||
 		len(recipientsUsernames) <= 0 || message == "" ||
-		len(message) > common.MaxMessageLength ||
-		len(images) > common.MaxImages {
+		len(message) > limits.MaxMessageLength ||
+		len(images) > limits.MaxImages {
 		return nil, errors.New("too much or not enough data to construct post")
 	}
 
@@ -134,6 +562,7 @@ func NewPost(
 		Recipients: recipients,
 		Message:    message,
 		Images:     byteImages,
+		Visibility: Public,
 	}
 	post.PostID = crypto.Sha3String(post.String())
 	post.Timestamp = time.Now()
@@ -141,6 +570,187 @@ func NewPost(
 
 }
 
+// ParseVisibility parses the visibility of a post. Posts are public if no
+// visibility is given.
+func ParseVisibility(visibility string) (Visibility, error) {
+	switch Visibility(visibility) {
+	case "":
+		return Public, nil
+	case Public, RecipientsOnly, Private:
+		return Visibility(visibility), nil
+	}
+	return "", fmt.Errorf("invalid visibility '%s'", visibility)
+}
+
+// ParseMentions finds the mentions of usernames in a message, in the order
+// that they appear. Usernames are lowercased, but are not checked against
+// the users that exist.
+func ParseMentions(message string) []Mention {
+	var mentions []Mention
+	offset, units := 0, 0 // A byte offset and its offset in UTF-16
+	for _, match := range mentionPattern.FindAllStringSubmatchIndex(message, -1) {
+		start, end := match[4]-1, match[5] // Include the @
+		units += utf16Len(message[offset:start])
+		startUnits := units
+		units += utf16Len(message[start:end])
+		offset = end
+
+		mentions = append(mentions, Mention{
+			Username: Username(strings.ToLower(message[start+1 : end])),
+			Text:     message[start:end],
+			Start:    startUnits,
+			End:      units,
+		})
+	}
+	return mentions
+}
+
+// utf16Len is the length of a string in UTF-16 code units.
+func utf16Len(s string) int {
+	n := 0
+	for _, r := range s {
+		if r >= 0x10000 {
+			n += 2 // A surrogate pair
+		} else {
+			n++
+		}
+	}
+	return n
+}
+
+// NewReport creates a new report of a post.
+func NewReport(
+	postID string,
+	reporterUsername string,
+	category string,
+	reason string,
+	limits common.Limits,
+) (*Report, error) {
+	switch ReportCategory(category) {
+	case Harassment, Inappropriate, Impersonation, Spam, Other:
+	default:
+		return nil, fmt.Errorf("invalid report category '%s'", category)
+	}
+	if postID == "" || len(reason) > limits.MaxMessageLength {
+		return nil, errors.New("too much or not enough data to construct report")
+	}
+
+	reporter, err := validateUsername(reporterUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Report{
+		PostID:    postID,
+		Reporter:  reporter,
+		Category:  ReportCategory(category),
+		Reason:    reason,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// NewComment constructs a new comment on a post. The parentID is zero
+// for top-level comments.
+func NewComment(
+	postID string,
+	authorUsername string,
+	message string,
+	parentID int64,
+	limits common.Limits,
+) (*Comment, error) {
+	if postID == "" || parentID < 0 || !validMessage(message, limits) {
+		return nil, errors.New("too much or not enough data to construct comment")
+	}
+
+	author, err := validateUsername(authorUsername)
+	if err != nil {
+		return nil, err
+	}
+
+	return &Comment{
+		PostID:    postID,
+		ParentID:  parentID,
+		Author:    author,
+		Message:   message,
+		Timestamp: time.Now(),
+	}, nil
+}
+
+// Edit replaces the message of a comment.
+func (comment *Comment) Edit(message string, limits common.Limits) error {
+	if !validMessage(message, limits) {
+		return errors.New("too much or not enough data to edit comment")
+	}
+	comment.Message = message
+	comment.EditedAt = time.Now()
+	return nil
+}
+
+// validMessage checks that a message is not empty and not too long.
+func validMessage(message string, limits common.Limits) bool {
+	return message != "" && len(message) <= limits.MaxMessageLength
+}
+
+// NewNotification creates a new email notification that is ready to be
+// sent.
+func NewNotification(
+	kind string,
+	to []string,
+	subject string,
+	text string,
+	html string,
+) *Notification {
+	now := time.Now()
+	return &Notification{
+		Kind:        kind,
+		To:          to,
+		Subject:     subject,
+		Text:        text,
+		HTML:        html,
+		NextAttempt: now,
+		CreatedAt:   now,
+	}
+}
+
+// DefaultPreferences returns the notification preferences of a user who
+// has not changed them.
+func DefaultPreferences(username Username) *NotificationPreferences {
+	return &NotificationPreferences{
+		Username:     username,
+		EmailEnabled: true,
+		Cadences:     make(map[string]Cadence),
+	}
+}
+
+// CadenceFor returns how often the user should be emailed about an event.
+func (prefs *NotificationPreferences) CadenceFor(event string) Cadence {
+	if !prefs.EmailEnabled {
+		return Never
+	}
+	if cadence, ok := prefs.Cadences[event]; ok {
+		return cadence
+	}
+	return Immediate
+}
+
+// NewInAppNotification creates a new unread in-app notification.
+func NewInAppNotification(
+	username Username,
+	kind string,
+	actor Username,
+	postID string,
+	message string,
+) *InAppNotification {
+	return &InAppNotification{
+		Username:  username,
+		Kind:      kind,
+		Actor:     actor,
+		PostID:    postID,
+		Message:   message,
+		Timestamp: time.Now(),
+	}
+}
+
 // UserFromString returns a new User given a JSON/string representation
 // of a user struct.
 func UserFromString(data string) (*User, error) {
@@ -212,12 +822,57 @@ func validateUsername(u string) (Username, error) {
 	return Username(""), fmt.Errorf("invalid username '%s", u)
 }
 
+// HasBlocked checks if the user has blocked another user.
+func (user *User) HasBlocked(username Username) bool {
+	for _, blocked := range user.Blocked {
+		if blocked == username {
+			return true
+		}
+	}
+	return false
+}
+
 // String marshals a user to a string.
 func (user *User) String() string {
 	json, _ := json.MarshalIndent(*user, " ", "  ")
 	return string(json)
 }
 
+// HasRecipient checks if a user is one of the recipients of a post.
+func (post *Post) HasRecipient(username Username) bool {
+	for _, recipient := range post.Recipients {
+		if recipient == username {
+			return true
+		}
+	}
+	return false
+}
+
+// Unpublished checks if a post is a draft or is scheduled to be published.
+func (post *Post) Unpublished() bool {
+	return post.Status == Draft || post.Status == Scheduled
+}
+
+// VisibleTo checks if a viewer can see a post. Posts that are not live can
+// only be seen by their sender.
+func (post *Post) VisibleTo(viewer Viewer) bool {
+	if viewer.Moderator {
+		return true
+	}
+	if post.Status != Approved {
+		return post.Sender == viewer.Username
+	}
+
+	switch post.Visibility {
+	case RecipientsOnly:
+		return post.HasRecipient(viewer.Username)
+	case Private:
+		return post.Sender == viewer.Username ||
+			post.HasRecipient(viewer.Username)
+	}
+	return true
+}
+
 // String marshals a post to a string.
 func (post *Post) String() string {
 	json, _ := json.MarshalIndent(*post, " ", "  ")
@@ -1,6 +1,11 @@
 package models
 
-import "testing"
+import (
+	"testing"
+	"unicode/utf16"
+
+	"github.com/mattnappo/yearbook/common"
+)
 
 func TestNewUser(t *testing.T) {
 	user, err := NewUser("first.last@mastersny.org", Freshman, false)
@@ -17,6 +22,7 @@ func TestNewPost(t *testing.T) {
 		"Hi, this is a test message!",
 		[]string{"dW5pcXVlIGltYWdlIGRhdGEgb25l", "dW5pcXVlIGltYWdlIGRhdGEgdHdv"},
 		[]string{"recip.one", "recip.two"},
+		common.DefaultLimits,
 	)
 
 	if err != nil {
@@ -39,3 +45,112 @@ func TestUserFromString(t *testing.T) {
 	}
 	t.Log(stringedUser.String())
 }
+
+func TestNewComment(t *testing.T) {
+	comment, err := NewComment("post-id", "comm.enter", "Congrats!", 0, common.DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(comment)
+
+	_, err = NewComment("post-id", "comm.enter", "", 0, common.DefaultLimits)
+	if err == nil {
+		t.Fatal("expected an error for an empty comment")
+	}
+
+	err = comment.Edit("Congratulations!", common.DefaultLimits)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Log(comment)
+}
+
+func TestVisibleTo(t *testing.T) {
+	post, err := NewPost(
+		"sen.der",
+		"Hi, this is a test message!",
+		[]string{},
+		[]string{"recip.one"},
+		common.DefaultLimits,
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	post.Status = Approved
+
+	cases := []struct {
+		visibility Visibility
+		sender     bool
+		recipient  bool
+		stranger   bool
+	}{
+		{Public, true, true, true},
+		{RecipientsOnly, false, true, false},
+		{Private, true, true, false},
+	}
+	for _, c := range cases {
+		post.Visibility = c.visibility
+		if post.VisibleTo(Viewer{Username: "sen.der"}) != c.sender ||
+			post.VisibleTo(Viewer{Username: "recip.one"}) != c.recipient ||
+			post.VisibleTo(Viewer{Username: "some.one"}) != c.stranger ||
+			!post.VisibleTo(Viewer{Moderator: true}) {
+			t.Fatalf("wrong visibility for %s post", c.visibility)
+		}
+	}
+
+	// Posts that are not live can only be seen by their sender
+	post.Visibility = Public
+	post.Status = Pending
+	if !post.VisibleTo(Viewer{Username: "sen.der"}) ||
+		post.VisibleTo(Viewer{Username: "recip.one"}) {
+		t.Fatal("wrong visibility for pending post")
+	}
+}
+
+func TestParseMentions(t *testing.T) {
+	message := "Congrats @John.Smith and @jane.doe! Email me at me.you@mastersny.org"
+	mentions := ParseMentions(message)
+	if len(mentions) != 2 {
+		t.Fatalf("expected 2 mentions, got %d", len(mentions))
+	}
+
+	expected := []Username{"john.smith", "jane.doe"}
+	for i, mention := range mentions {
+		if mention.Username != expected[i] {
+			t.Fatalf("expected mention of %s, got %s", expected[i], mention.Username)
+		}
+		if message[mention.Start] != '@' {
+			t.Fatalf("mention span does not start at the @")
+		}
+		if mention.Text != message[mention.Start:mention.End] {
+			t.Fatalf("mention text %s does not match its span", mention.Text)
+		}
+	}
+
+	// Offsets count UTF-16 code units, like JavaScript strings
+	message = "🎉 Congrats, José and @first.last!"
+	mentions = ParseMentions(message)
+	if len(mentions) != 1 {
+		t.Fatalf("expected 1 mention, got %d", len(mentions))
+	}
+	units := utf16.Encode([]rune(message))
+	span := string(utf16.Decode(units[mentions[0].Start:mentions[0].End]))
+	if span != "@first.last" || mentions[0].Text != span {
+		t.Fatalf("mention span is %s, text is %s", span, mentions[0].Text)
+	}
+}
+
+func TestParseGrade(t *testing.T) {
+	grade, err := ParseGrade("Senior")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if grade != Senior || grade.String() != "senior" {
+		t.Fatalf("parsed the wrong grade: %s", grade)
+	}
+
+	_, err = ParseGrade("kindergarten")
+	if err == nil {
+		t.Fatal("expected an error for an invalid grade")
+	}
+}